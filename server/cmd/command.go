@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/internal/initialize"
+	"interastral-peace.com/alnitak/internal/service"
+	"interastral-peace.com/alnitak/pkg/mysql"
+	"interastral-peace.com/alnitak/pkg/oss"
+	"interastral-peace.com/alnitak/pkg/redis"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 执行子命令，如 alnitak -env prod retranscode -all
+func runCommand(args []string) {
+	switch args[0] {
+	case "retranscode":
+		retranscodeCommand(args[1:])
+	default:
+		fmt.Println("未知的命令:", args[0])
+		os.Exit(1)
+	}
+}
+
+// 重新转码，任务加入转码队列后由服务端的转码协程执行
+func retranscodeCommand(args []string) {
+	fs := flag.NewFlagSet("retranscode", flag.ExitOnError)
+	resources := fs.String("resources", "", "资源ID，多个用逗号分隔")
+	partition := fs.Uint("partition", 0, "分区ID，包含子分区")
+	all := fs.Bool("all", false, "重新转码全部视频")
+	fs.Parse(args)
+
+	retranscodeReq := dto.RetranscodeReq{PartitionId: *partition, All: *all}
+	for _, id := range strings.Split(*resources, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			retranscodeReq.ResourceIds = append(retranscodeReq.ResourceIds, utils.StringToUint(id))
+		}
+	}
+
+	// 初始化OSS
+	if global.Config.Storage.OssType != "local" {
+		global.Storage = oss.InitStorage(global.Config.Storage)
+	}
+	// 初始化雪花ID
+	initialize.InitSnowflake()
+	// 初始化mysql
+	global.Mysql = mysql.Init(global.Config.Mysql)
+	initialize.InitTables()
+	// 初始化缓存，用于通知转码worker
+	global.Redis = redis.Init(global.Config.Redis)
+
+	count, err := service.RetranscodeResources(retranscodeReq)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("已加入转码队列的资源数量: %d\n", count)
+}
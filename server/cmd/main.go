@@ -24,6 +24,13 @@ func main() {
 	initialize.InitConfig(*env)
 	// 初始化日志
 	logger.InitLogger()
+
+	// 执行子命令
+	if flag.NArg() > 0 {
+		runCommand(flag.Args())
+		return
+	}
+
 	// 初始化滑块验证码生成
 	jigsaw.Jigsaw()
 	// 初始化OSS
@@ -43,6 +50,14 @@ func main() {
 	initialize.InitCacheData()
 	// 初始化casbin
 	global.Casbin = casbin.InitCasbin()
+	// 转发转码进度
+	service.InitTranscodingMessage()
+	// 上传源文件到OSS，完成后加入转码队列
+	service.InitSourceUploader()
+	// 启动转码队列，关闭后由独立的worker执行
+	if global.Config.Transcoding.EmbeddedWorker {
+		service.InitTranscodingQueue()
+	}
 
 	// 手动执行一次刷新热点视频
 	cron.RefreshPopular()
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+
+	"go.uber.org/zap"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/internal/initialize"
+	"interastral-peace.com/alnitak/internal/service"
+	"interastral-peace.com/alnitak/pkg/logger"
+	"interastral-peace.com/alnitak/pkg/mysql"
+	"interastral-peace.com/alnitak/pkg/oss"
+	"interastral-peace.com/alnitak/pkg/redis"
+)
+
+// 独立的转码worker，从redis获取任务通知，与API服务共用配置文件、数据库及存储
+func main() {
+	env := flag.String("env", "prod", "dev/prod")
+	flag.Parse()
+
+	// 初始化配置文件
+	initialize.InitConfig(*env)
+	// 初始化日志
+	logger.InitLogger()
+	// 初始化OSS，worker从OSS获取源文件，本地存储时需要与API服务共用upload目录
+	if global.Config.Storage.OssType != "local" {
+		global.Storage = oss.InitStorage(global.Config.Storage)
+	} else {
+		zap.L().Warn("未使用OSS存储，worker需要与API服务共用upload目录", zap.String("module", "transcoding"))
+	}
+	// 初始化mysql
+	global.Mysql = mysql.Init(global.Config.Mysql)
+	initialize.InitTables()
+	// 初始化缓存
+	global.Redis = redis.Init(global.Config.Redis)
+	// 启动转码队列
+	service.InitTranscodingQueue()
+
+	zap.L().Info("转码worker已启动", zap.String("module", "transcoding"))
+	select {}
+}
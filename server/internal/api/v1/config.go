@@ -78,3 +78,27 @@ func SetOtherConfig(ctx *gin.Context) {
 	// 返回给前端
 	resp.Ok(ctx)
 }
+
+// 获取转码配置信息
+func GetTranscodingConfig(ctx *gin.Context) {
+	config := service.GetTranscodingConfig()
+
+	resp.OkWithData(ctx, gin.H{"config": config})
+}
+
+// 修改转码配置
+func SetTranscodingConfig(ctx *gin.Context) {
+	var transcodingConfigReq dto.TranscodingConfigReq
+	if err := ctx.Bind(&transcodingConfigReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	if err := service.SetTranscodingConfig(transcodingConfigReq); err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.Ok(ctx)
+}
@@ -49,6 +49,133 @@ func GetVideoFileManage(ctx *gin.Context) {
 	resp.OkWithString(ctx, file)
 }
 
+// 获取主播放列表
+func GetVideoMasterFile(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetVideoMasterFile(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/plain; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取主播放列表(后台管理)
+func GetVideoMasterFileManage(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetVideoMasterFileManage(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/plain; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取DASH描述文件
+func GetVideoMpdFile(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetVideoMpdFile(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "application/dash+xml; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取DASH描述文件(后台管理)
+func GetVideoMpdFileManage(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetVideoMpdFileManage(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "application/dash+xml; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取切片解密密钥
+func GetVideoKey(ctx *gin.Context) {
+	key := ctx.Query("key")
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	secret, err := service.GetVideoKey(resourceId, key)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Data(http.StatusOK, "application/octet-stream", secret)
+}
+
+// 获取字幕播放列表
+func GetSubtitleFile(ctx *gin.Context) {
+	subtitleId := utils.StringToUint(ctx.Query("subtitleId"))
+
+	file, err := service.GetSubtitleFile(ctx, subtitleId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/plain; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取字幕播放列表(后台管理)
+func GetSubtitleFileManage(ctx *gin.Context) {
+	subtitleId := utils.StringToUint(ctx.Query("subtitleId"))
+
+	file, err := service.GetSubtitleFileManage(ctx, subtitleId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/plain; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取缩略图WebVTT
+func GetStoryboard(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetStoryboard(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/vtt; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
+// 获取章节WebVTT
+func GetChapterFile(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	file, err := service.GetChapterFile(ctx, resourceId)
+	if err != nil {
+		resp.ForbiddenWithMessage(ctx, err.Error())
+		return
+	}
+
+	ctx.Writer.Header().Set("Content-type", "text/vtt; charset=utf-8")
+	resp.OkWithString(ctx, file)
+}
+
 // 获取视频切片
 func GetVideoSlice(ctx *gin.Context) {
 	key := ctx.Query("key")
@@ -60,8 +187,15 @@ func GetVideoSlice(ctx *gin.Context) {
 		return
 	}
 
+	contentType, ok := service.GetVideoSliceContentType(file)
+	if !ok {
+		resp.Forbidden(ctx)
+		return
+	}
+
 	// 使用本地存储
 	if global.Config.Storage.OssType == "local" {
+		ctx.Header("Content-Type", contentType)
 		ctx.File("./upload/video/" + dir + "/" + file)
 		return
 	}
@@ -74,3 +74,109 @@ func GetResourceQualityManage(ctx *gin.Context) {
 	// 返回给前端
 	resp.OkWithData(ctx, gin.H{"quality": quality})
 }
+
+// 上传字幕
+func UploadSubtitle(ctx *gin.Context) {
+	// 获取参数
+	resourceId := utils.StringToUint(ctx.PostForm("resourceId"))
+	lang := ctx.PostForm("lang")
+	name := ctx.PostForm("name")
+	file, err := ctx.FormFile("subtitle")
+	if err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	subtitle, err := service.UploadSubtitle(ctx, resourceId, lang, name, file)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"subtitle": subtitle})
+}
+
+// 删除字幕
+func DeleteSubtitle(ctx *gin.Context) {
+	// 获取参数
+	id := utils.StringToUint(ctx.Param("id"))
+
+	if err := service.DeleteSubtitle(ctx, id); err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回
+	resp.Ok(ctx)
+}
+
+// 获取字幕列表
+func GetSubtitleList(ctx *gin.Context) {
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+
+	subtitles, err := service.GetSubtitleList(ctx, resourceId)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"subtitles": subtitles})
+}
+
+// 剪辑资源
+func ClipResource(ctx *gin.Context) {
+	// 获取参数
+	var clipReq dto.ClipResourceReq
+	if err := ctx.Bind(&clipReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	if err := service.ClipResource(ctx, clipReq); err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回
+	resp.Ok(ctx)
+}
+
+// 设置章节
+func SetChapters(ctx *gin.Context) {
+	// 获取参数
+	var setChaptersReq dto.SetChaptersReq
+	if err := ctx.Bind(&setChaptersReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	chapters, err := service.SetChapters(ctx, setChaptersReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"chapters": chapters})
+}
+
+// 导入章节
+func ImportChapters(ctx *gin.Context) {
+	// 获取参数
+	var importChaptersReq dto.ImportChaptersReq
+	if err := ctx.Bind(&importChaptersReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	chapters, err := service.ImportChapters(ctx, importChaptersReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"chapters": chapters})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/internal/service"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// tus协议信息
+func TusOptions(ctx *gin.Context) {
+	ctx.Header("Tus-Resumable", service.TUS_VERSION)
+	ctx.Header("Tus-Version", service.TUS_VERSION)
+	ctx.Header("Tus-Extension", service.TUS_EXTENSIONS)
+	ctx.Header("Tus-Checksum-Algorithm", service.TUS_CHECKSUM_ALGORITHMS)
+	ctx.Header("Tus-Max-Size", strconv.FormatInt(global.Config.File.MaxVideoSize*utils.MB, 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+// 创建上传
+func TusCreateUpload(ctx *gin.Context) {
+	if !checkTusResumable(ctx) {
+		return
+	}
+
+	// 获取参数
+	length, err := strconv.ParseInt(ctx.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		tusFail(ctx, http.StatusBadRequest, "请求参数有误")
+		return
+	}
+
+	info, err := service.TusCreateUpload(ctx, length, ctx.GetHeader("Upload-Metadata"))
+	if err != nil {
+		tusFailWithError(ctx, err)
+		return
+	}
+
+	ctx.Header("Location", ctx.Request.URL.Path+"/"+info.ID)
+	ctx.Header("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	ctx.Status(http.StatusCreated)
+}
+
+// 获取上传进度
+func TusGetUpload(ctx *gin.Context) {
+	if !checkTusResumable(ctx) {
+		return
+	}
+
+	info, err := service.TusGetUpload(ctx, ctx.Param("id"))
+	if err != nil {
+		tusFailWithError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	ctx.Header("Upload-Length", strconv.FormatInt(info.Length, 10))
+	ctx.Status(http.StatusOK)
+}
+
+// 上传数据
+func TusPatchUpload(ctx *gin.Context) {
+	if !checkTusResumable(ctx) {
+		return
+	}
+
+	// 获取参数
+	if ctx.ContentType() != "application/offset+octet-stream" {
+		tusFail(ctx, http.StatusUnsupportedMediaType, "请求类型有误")
+		return
+	}
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		tusFail(ctx, http.StatusBadRequest, "请求参数有误")
+		return
+	}
+
+	info, err := service.TusPatchUpload(ctx, ctx.Param("id"), offset, ctx.GetHeader("Upload-Checksum"), ctx.Request.Body)
+	if err != nil {
+		tusFailWithError(ctx, err)
+		return
+	}
+
+	ctx.Header("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	ctx.Status(http.StatusNoContent)
+}
+
+// 终止上传
+func TusTerminateUpload(ctx *gin.Context) {
+	if !checkTusResumable(ctx) {
+		return
+	}
+
+	if err := service.TusTerminateUpload(ctx, ctx.Param("id")); err != nil {
+		tusFailWithError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// 校验协议版本
+func checkTusResumable(ctx *gin.Context) bool {
+	ctx.Header("Tus-Resumable", service.TUS_VERSION)
+	if ctx.GetHeader("Tus-Resumable") != service.TUS_VERSION {
+		ctx.Header("Tus-Version", service.TUS_VERSION)
+		tusFail(ctx, http.StatusPreconditionFailed, "不支持的协议版本")
+		return false
+	}
+
+	return true
+}
+
+func tusFailWithError(ctx *gin.Context, err error) {
+	var tusErr *service.TusError
+	if errors.As(err, &tusErr) {
+		tusFail(ctx, tusErr.Status, tusErr.Msg)
+		return
+	}
+
+	tusFail(ctx, http.StatusInternalServerError, err.Error())
+}
+
+func tusFail(ctx *gin.Context, status int, message string) {
+	ctx.String(status, message)
+}
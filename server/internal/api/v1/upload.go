@@ -1,6 +1,8 @@
 package api
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 	"interastral-peace.com/alnitak/internal/domain/dto"
 	"interastral-peace.com/alnitak/internal/resp"
@@ -33,14 +35,18 @@ func UploadVideoCreate(ctx *gin.Context) {
 		return
 	}
 
-	resource, err := service.UploadVideoCreate(ctx, videoFileReq)
+	resource, covers, err := service.UploadVideoCreate(ctx, videoFileReq)
+	if errors.Is(err, service.ErrNoVideoStream) {
+		resp.Result(ctx, resp.INVALID_MEDIA, nil, err.Error())
+		return
+	}
 	if err != nil {
 		resp.FailWithMessage(ctx, err.Error())
 		return
 	}
 
 	// 返回给前端
-	resp.OkWithData(ctx, gin.H{"resource": resource})
+	resp.OkWithData(ctx, gin.H{"resource": resource, "covers": covers})
 }
 
 func UploadVideoAdd(ctx *gin.Context) {
@@ -55,6 +61,10 @@ func UploadVideoAdd(ctx *gin.Context) {
 	}
 
 	resource, err := service.UploadVideoAdd(ctx, vid, videoFileReq)
+	if errors.Is(err, service.ErrNoVideoStream) {
+		resp.Result(ctx, resp.INVALID_MEDIA, nil, err.Error())
+		return
+	}
 	if err != nil {
 		resp.FailWithMessage(ctx, err.Error())
 		return
@@ -88,7 +98,12 @@ func UploadVideoMerge(ctx *gin.Context) {
 		return
 	}
 
-	if err := service.UploadVideoMerge(ctx, videoFileReq); err != nil {
+	missing, err := service.UploadVideoMerge(ctx, videoFileReq)
+	if len(missing) > 0 {
+		resp.FailWithDetailed(ctx, gin.H{"chunks": missing}, err.Error())
+		return
+	}
+	if err != nil {
 		resp.FailWithMessage(ctx, err.Error())
 		return
 	}
@@ -105,12 +120,119 @@ func UploadVideoCheck(ctx *gin.Context) {
 		return
 	}
 
-	chunks, err := service.UploadVideoCheck(ctx, videoFileReq)
+	chunks, instant, err := service.UploadVideoCheck(ctx, videoFileReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"chunks": chunks, "instant": instant})
+}
+
+// 初始化OSS直传
+func OssUploadInit(ctx *gin.Context) {
+	// 获取参数
+	var initReq dto.OssUploadInitReq
+	if err := ctx.Bind(&initReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	info, err := service.OssUploadInit(ctx, initReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"partSize": info.PartSize, "partCount": info.PartCount, "completed": info.Completed})
+}
+
+// 获取分片上传地址
+func OssUploadPresign(ctx *gin.Context) {
+	// 获取参数
+	var presignReq dto.OssUploadPresignReq
+	if err := ctx.Bind(&presignReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	urls, err := service.OssUploadPresign(ctx, presignReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"urls": urls})
+}
+
+// 完成OSS直传
+func OssUploadComplete(ctx *gin.Context) {
+	// 获取参数
+	var completeReq dto.OssUploadCompleteReq
+	if err := ctx.Bind(&completeReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	if err := service.OssUploadComplete(ctx, completeReq); err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回
+	resp.Ok(ctx)
+}
+
+// 取消OSS直传
+func OssUploadAbort(ctx *gin.Context) {
+	// 获取参数
+	var videoFileReq dto.VideoFileReq
+	if err := ctx.Bind(&videoFileReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	if err := service.OssUploadAbort(ctx, videoFileReq); err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回
+	resp.Ok(ctx)
+}
+
+// 从URL导入视频
+func ImportVideo(ctx *gin.Context) {
+	// 获取参数
+	var importReq dto.ImportVideoReq
+	if err := ctx.Bind(&importReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	id, err := service.ImportVideo(ctx, importReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"id": id})
+}
+
+// 获取视频导入进度
+func GetVideoImport(ctx *gin.Context) {
+	id := ctx.Query("id")
+
+	progress, err := service.GetVideoImport(ctx, id)
 	if err != nil {
 		resp.FailWithMessage(ctx, err.Error())
 		return
 	}
 
 	// 返回给前端
-	resp.OkWithData(ctx, gin.H{"chunks": chunks})
+	resp.OkWithData(ctx, gin.H{"progress": progress})
 }
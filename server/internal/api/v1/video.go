@@ -45,6 +45,17 @@ func GetVideoStatus(ctx *gin.Context) {
 	resp.OkWithData(ctx, gin.H{"video": video})
 }
 
+// 转码进度Websocket连接
+func GetTranscodingProgressConnect(ctx *gin.Context) {
+	videoId := utils.StringToUint(ctx.Query("vid"))
+	if videoId == 0 {
+		return
+	}
+
+	// 升级为websocket长链接
+	service.GetTranscodingProgressConnect(ctx, videoId)
+}
+
 // 获取自己的视频
 func GetUploadVideoList(ctx *gin.Context) {
 	page := utils.StringToInt(ctx.Query("page"))
@@ -171,6 +182,45 @@ func DeleteVideoManage(ctx *gin.Context) {
 	resp.Ok(ctx)
 }
 
+// 获取转码任务列表(后台管理)
+func GetTranscodingTaskListManage(ctx *gin.Context) {
+	// 获取参数
+	var taskListReq dto.TranscodingTaskListReq
+	if err := ctx.Bind(&taskListReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	if taskListReq.PageSize > 100 {
+		resp.FailWithMessage(ctx, "请求数量过多")
+		return
+	}
+
+	total, tasks := service.GetTranscodingTaskListManage(taskListReq)
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"list": tasks, "total": total})
+}
+
+// 重新转码(后台管理)
+func RetranscodeManage(ctx *gin.Context) {
+	// 获取参数
+	var retranscodeReq dto.RetranscodeReq
+	if err := ctx.Bind(&retranscodeReq); err != nil {
+		resp.FailWithMessage(ctx, "请求参数有误")
+		return
+	}
+
+	count, err := service.RetranscodeResources(retranscodeReq)
+	if err != nil {
+		resp.FailWithMessage(ctx, err.Error())
+		return
+	}
+
+	// 返回
+	resp.OkWithData(ctx, gin.H{"count": count})
+}
+
 // 获取待审核视频列表
 func GetReviewList(ctx *gin.Context) {
 	// 获取参数
@@ -201,6 +251,16 @@ func GetReviewResourceList(ctx *gin.Context) {
 	resp.OkWithData(ctx, gin.H{"resources": resources})
 }
 
+// 获取资源的转码日志
+func GetTranscodingLogList(ctx *gin.Context) {
+	// 获取参数
+	resourceId := utils.StringToUint(ctx.Query("resourceId"))
+	tasks, logs := service.GetTranscodingLogList(resourceId)
+
+	// 返回给前端
+	resp.OkWithData(ctx, gin.H{"tasks": tasks, "logs": logs})
+}
+
 // 获取热门视频
 func GetHotVideo(ctx *gin.Context) {
 	page := utils.StringToInt(ctx.Query("page"))
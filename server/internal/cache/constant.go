@@ -53,6 +53,9 @@ const UPLOAD_IMAGE_KEY = "upload_image_key:"
 // 上传文件过期时间 n 分钟
 const UPLOAD_IMAGE_EXPRIRATION_TIME = time.Minute * time.Duration(20)
 
+// 候选封面标识符，有序集合，分数为生成时间
+const COVER_CANDIDATE_KEY = "cover_candidate_key"
+
 // 视频分区缓存标识符
 const VIDEO_PARTITION_KEY = "video_partition_key"
 
@@ -115,3 +118,21 @@ const ARTICLE_CLICKS_KEY = "article_clicks_key:"
 
 // 文章量过期时间  n 小时
 const ARTICLE_CLICKS_EXPRIRATION_TIME = time.Hour * time.Duration(24)
+
+// 转码进度标识符
+const TRANSCODING_PROGRESS_KEY = "transcoding_progress_key:"
+
+// 转码进度过期时间 n 小时
+const TRANSCODING_PROGRESS_EXPRIRATION_TIME = time.Hour * time.Duration(24)
+
+// 转码任务通知队列
+const TRANSCODING_QUEUE_KEY = "transcoding_queue_key"
+
+// 转码进度消息频道
+const TRANSCODING_MESSAGE_CHANNEL = "transcoding_message_channel"
+
+// URL导入视频进度标识符
+const VIDEO_IMPORT_KEY = "video_import_key:"
+
+// URL导入视频进度过期时间 n 小时
+const VIDEO_IMPORT_EXPRIRATION_TIME = time.Hour * time.Duration(24)
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"encoding/json"
+	"time"
+
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+func GetTranscodingProgress(resourceId uint) (progress []vo.TranscodingProgressResp) {
+	values := global.Redis.HGetAll(TRANSCODING_PROGRESS_KEY + utils.UintToString(resourceId))
+	for _, v := range values {
+		var p vo.TranscodingProgressResp
+		if err := json.Unmarshal([]byte(v), &p); err != nil {
+			utils.ErrorLog("转码进度反序列化失败", "cache", err.Error())
+			continue
+		}
+		progress = append(progress, p)
+	}
+	return
+}
+
+func SetTranscodingProgress(resourceId uint, progress vo.TranscodingProgressResp) {
+	pb, err := json.Marshal(progress)
+	if err != nil {
+		utils.ErrorLog("转码进度序列化失败", "cache", err.Error())
+		return
+	}
+
+	key := TRANSCODING_PROGRESS_KEY + utils.UintToString(resourceId)
+	global.Redis.HSet(key, progress.Target, pb)
+	global.Redis.Expire(key, TRANSCODING_PROGRESS_EXPRIRATION_TIME)
+}
+
+func DelTranscodingProgress(resourceId uint) {
+	global.Redis.Del(TRANSCODING_PROGRESS_KEY + utils.UintToString(resourceId))
+}
+
+// 通知工作进程有新的转码任务
+func PushTranscodingTask(taskId uint) {
+	global.Redis.LPush(TRANSCODING_QUEUE_KEY, taskId)
+}
+
+// 等待转码任务通知，超时返回false
+func WaitTranscodingTask(timeout time.Duration) bool {
+	start := time.Now()
+	if _, ok := global.Redis.BRPop(timeout, TRANSCODING_QUEUE_KEY); ok {
+		return true
+	}
+
+	// redis不可用时立即返回，等待到超时避免空转
+	if elapsed := time.Since(start); elapsed < timeout {
+		time.Sleep(timeout - elapsed)
+	}
+	return false
+}
+
+// 发布转码进度消息
+func PublishTranscodingMessage(msg vo.TranscodingProgressMsg) {
+	mb, err := json.Marshal(msg)
+	if err != nil {
+		utils.ErrorLog("转码进度序列化失败", "cache", err.Error())
+		return
+	}
+
+	global.Redis.Publish(TRANSCODING_MESSAGE_CHANNEL, mb)
+}
+
+// 订阅转码进度消息
+func SubscribeTranscodingMessage(handler func(msg vo.TranscodingProgressMsg)) {
+	for payload := range global.Redis.Subscribe(TRANSCODING_MESSAGE_CHANNEL) {
+		var msg vo.TranscodingProgressMsg
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			utils.ErrorLog("转码进度反序列化失败", "cache", err.Error())
+			continue
+		}
+		handler(msg)
+	}
+}
@@ -1,8 +1,11 @@
 package cache
 
 import (
+	"encoding/json"
 	"strconv"
+	"time"
 
+	"interastral-peace.com/alnitak/internal/domain/vo"
 	"interastral-peace.com/alnitak/internal/global"
 	"interastral-peace.com/alnitak/utils"
 )
@@ -27,3 +30,39 @@ func SetUploadImage(url string, userID uint) {
 func DelUploadImage(url string) {
 	global.Redis.Del(UPLOAD_IMAGE_KEY + url)
 }
+
+// 记录生成的候选封面
+func AddCoverCandidate(url string) {
+	global.Redis.ZAdd(COVER_CANDIDATE_KEY, float64(time.Now().Unix()), url)
+}
+
+// 获取在指定时间之前生成的候选封面
+func GetCoverCandidatesBefore(before time.Time) []string {
+	return global.Redis.ZRangeByScore(COVER_CANDIDATE_KEY, "-inf", strconv.FormatInt(before.Unix(), 10))
+}
+
+func DelCoverCandidate(url string) {
+	global.Redis.ZRem(COVER_CANDIDATE_KEY, url)
+}
+
+func GetVideoImport(id string) (progress vo.VideoImportResp) {
+	s := global.Redis.Get(VIDEO_IMPORT_KEY + id)
+	if s == "" {
+		return
+	}
+
+	if err := json.Unmarshal([]byte(s), &progress); err != nil {
+		utils.ErrorLog("导入进度反序列化失败", "cache", err.Error())
+	}
+	return
+}
+
+func SetVideoImport(progress vo.VideoImportResp) {
+	pb, err := json.Marshal(progress)
+	if err != nil {
+		utils.ErrorLog("导入进度序列化失败", "cache", err.Error())
+		return
+	}
+
+	global.Redis.Set(VIDEO_IMPORT_KEY+progress.ID, pb, VIDEO_IMPORT_EXPRIRATION_TIME)
+}
@@ -1,6 +1,14 @@
 package config
 
 type File struct {
-	MaxImgSize   int64 `mapstructure:"max_img_size" json:"max_img_size" yaml:"max_img_size"`
-	MaxVideoSize int64 `mapstructure:"max_video_size" json:"max_video_size" yaml:"max_video_size"`
+	MaxImgSize   int64  `mapstructure:"max_img_size" json:"max_img_size" yaml:"max_img_size"`
+	MaxVideoSize int64  `mapstructure:"max_video_size" json:"max_video_size" yaml:"max_video_size"`
+	Import       Import `mapstructure:"import" json:"import" yaml:"import"`
+}
+
+// 从URL导入视频
+type Import struct {
+	Timeout      int      `mapstructure:"timeout" json:"timeout" yaml:"timeout"`                   // 下载超时时间(分钟)
+	AllowPrivate bool     `mapstructure:"allow_private" json:"allow_private" yaml:"allow_private"` // 是否允许内网地址
+	AllowHosts   []string `mapstructure:"allow_hosts" json:"allow_hosts" yaml:"allow_hosts"`       // 允许的内网地址或网段，如NAS地址
 }
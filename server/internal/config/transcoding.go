@@ -1,6 +1,37 @@
 package config
 
 type Transcoding struct {
-	UseGpu          bool `mapstructure:"use_gpu" json:"use_gpu" yaml:"use_gpu"`
-	Generate1080p60 bool `mapstructure:"generate_1080p60" json:"generate_1080p60" yaml:"generate_1080p60"`
+	UseGpu          bool              `mapstructure:"use_gpu" json:"use_gpu" yaml:"use_gpu"`
+	Generate1080p60 bool              `mapstructure:"generate_1080p60" json:"generate_1080p60" yaml:"generate_1080p60"`
+	EmbeddedWorker  bool              `mapstructure:"embedded_worker" json:"embedded_worker" yaml:"embedded_worker"`
+	WorkerCount     int               `mapstructure:"worker_count" json:"worker_count" yaml:"worker_count"`
+	MaxRetry        int               `mapstructure:"max_retry" json:"max_retry" yaml:"max_retry"`
+	Packaging       string            `mapstructure:"packaging" json:"packaging" yaml:"packaging"`
+	Encrypt         bool              `mapstructure:"encrypt" json:"encrypt" yaml:"encrypt"`
+	Loudnorm        bool              `mapstructure:"loudnorm" json:"loudnorm" yaml:"loudnorm"`
+	TargetLufs      float64           `mapstructure:"target_lufs" json:"target_lufs" yaml:"target_lufs"`
+	Ladder          []TranscodingRung `mapstructure:"ladder" json:"ladder" yaml:"ladder"`
+	Watermark       Watermark         `mapstructure:"watermark" json:"watermark" yaml:"watermark"`
+}
+
+// 转码阶梯中的一档输出
+type TranscodingRung struct {
+	Width        int    `mapstructure:"width" json:"width" yaml:"width"`
+	Height       int    `mapstructure:"height" json:"height" yaml:"height"`
+	Bitrate      string `mapstructure:"bitrate" json:"bitrate" yaml:"bitrate"`
+	MaxFps       int    `mapstructure:"max_fps" json:"max_fps" yaml:"max_fps"`
+	Codec        string `mapstructure:"codec" json:"codec" yaml:"codec"`
+	Crf          int    `mapstructure:"crf" json:"crf" yaml:"crf"`
+	Preset       string `mapstructure:"preset" json:"preset" yaml:"preset"`
+	AudioBitrate string `mapstructure:"audio_bitrate" json:"audio_bitrate" yaml:"audio_bitrate"`
+}
+
+// 转码水印，上传者选择添加水印时生效
+type Watermark struct {
+	Image    string  `mapstructure:"image" json:"image" yaml:"image"`
+	Text     bool    `mapstructure:"text" json:"text" yaml:"text"`
+	Position string  `mapstructure:"position" json:"position" yaml:"position"`
+	Opacity  float64 `mapstructure:"opacity" json:"opacity" yaml:"opacity"`
+	Scale    float64 `mapstructure:"scale" json:"scale" yaml:"scale"`
+	FontFile string  `mapstructure:"font_file" json:"font_file" yaml:"font_file"`
 }
@@ -11,5 +11,14 @@ func StartCronTask() {
 	// 每3小时刷新一次热点
 	c.Every(3).Hours().Do(RefreshPopular)
 
+	// 每小时清理重新转码后被替换的切片
+	c.Every(1).Hour().Do(CleanReplacedVideoFiles)
+
+	// 每小时清理没有被选择的候选封面
+	c.Every(1).Hour().Do(CleanCoverCandidates)
+
+	// 每天清理过期的转码日志
+	c.Every(1).Day().Do(CleanTranscodingLogs)
+
 	<-c.Start()
 }
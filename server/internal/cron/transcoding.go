@@ -0,0 +1,32 @@
+package cron
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"interastral-peace.com/alnitak/internal/service"
+)
+
+// 清理重新转码后被替换的切片
+func CleanReplacedVideoFiles() {
+	start := time.Now()
+	zap.L().Info("开始清理被替换的视频切片", zap.String("module", "cron"))
+	service.CleanReplacedVideoFiles()
+	zap.L().Info("被替换的视频切片清理完成，耗时:"+time.Since(start).String(), zap.String("module", "cron"))
+}
+
+// 清理过期的转码日志
+func CleanTranscodingLogs() {
+	start := time.Now()
+	zap.L().Info("开始清理转码日志", zap.String("module", "cron"))
+	service.CleanTranscodingLogs()
+	zap.L().Info("转码日志清理完成，耗时:"+time.Since(start).String(), zap.String("module", "cron"))
+}
+
+// 清理没有被选择的候选封面
+func CleanCoverCandidates() {
+	start := time.Now()
+	zap.L().Info("开始清理候选封面", zap.String("module", "cron"))
+	service.CleanCoverCandidates()
+	zap.L().Info("候选封面清理完成，耗时:"+time.Since(start).String(), zap.String("module", "cron"))
+}
@@ -22,8 +22,6 @@ type StorageConfigReq struct {
 	Region    string
 	Domain    string
 	Private   bool
-
-	UploadMp4File bool
 }
 
 type OtherConfigReq struct {
@@ -32,3 +30,35 @@ type OtherConfigReq struct {
 	Generate1080p60 bool
 	UseGpu          bool
 }
+
+type TranscodingConfigReq struct {
+	EmbeddedWorker bool
+	WorkerCount    int
+	MaxRetry       int
+	Packaging      string
+	Encrypt        bool
+	Loudnorm       bool
+	TargetLufs     float64
+	Ladder         []TranscodingRungReq
+	Watermark      WatermarkReq
+}
+
+type TranscodingRungReq struct {
+	Width        int
+	Height       int
+	Bitrate      string
+	MaxFps       int
+	Codec        string
+	Crf          int
+	Preset       string
+	AudioBitrate string
+}
+
+type WatermarkReq struct {
+	Image    string
+	Text     bool
+	Position string
+	Opacity  float64
+	Scale    float64
+	FontFile string
+}
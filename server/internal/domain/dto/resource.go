@@ -5,3 +5,35 @@ type ModifyResourceTitleReq struct {
 	ID    uint
 	Title string
 }
+
+// 剪辑资源，Ranges为空时使用Start和End，End为0表示到视频结尾
+// 片段时间基于上传的源视频，而不是上一次剪辑后的视频，每次剪辑都会替换之前的剪辑
+type ClipResourceReq struct {
+	ID     uint
+	Start  float64
+	End    float64
+	Ranges []ClipRange
+}
+
+// 保留的片段（秒）
+type ClipRange struct {
+	Start float64
+	End   float64
+}
+
+// 设置章节
+type SetChaptersReq struct {
+	ID       uint
+	Chapters []Chapter
+}
+
+// 导入章节，Source为desc或file
+type ImportChaptersReq struct {
+	ID     uint
+	Source string
+}
+
+type Chapter struct {
+	Start float64
+	Title string
+}
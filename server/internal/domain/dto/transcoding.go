@@ -1,16 +1,31 @@
 package dto
 
 type TranscodingInfo struct {
-	Width      int     // 视频宽度
-	Height     int     // 视频高度
-	Duration   float64 // 视频时长
-	DirName    string  // 目录名称
-	OutputDir  string  // 输出位置
-	InputFile  string  // 输入文件
-	ResourceID uint    // 资源ID
-	VideoID    uint    // 视频ID
-	CodecName  string  // 视频编码名称
-	FPS        string  // 视频帧率
-	FPS30      string  // 30帧实际帧率
-	FPS60      string  // 60帧实际帧率
+	Width      int         // 视频宽度
+	Height     int         // 视频高度
+	Duration   float64     // 视频时长
+	DirName    string      // 目录名称
+	OutputDir  string      // 输出位置
+	InputFile  string      // 输入文件
+	ResourceID uint        // 资源ID
+	VideoID    uint        // 视频ID
+	CodecName  string      // 视频编码名称
+	FPS        string      // 视频帧率
+	FPS30      string      // 30帧实际帧率
+	FPS60      string      // 60帧实际帧率
+	StreamIdx  int         // 视频流序号
+	Rotation   int         // 旋转角度
+	HDR        bool        // 是否为HDR
+	BitDepth   int         // 位深
+	HasAudio   bool        // 是否有音频
+	Watermark  bool        // 是否添加水印
+	Uploader   string      // 上传者用户名，用于文字水印
+	Clips      []ClipRange // 剪辑保留的片段，为空时使用完整视频
+	Chapters   []Chapter   // 视频文件中的章节
+}
+
+type TranscodingTaskListReq struct {
+	Page     int
+	PageSize int
+	Status   *int // 任务状态，为空时获取全部
 }
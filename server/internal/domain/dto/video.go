@@ -6,7 +6,36 @@ type VideoListReq struct {
 }
 
 type VideoFileReq struct {
+	Hash      string
+	Name      string // 文件名，秒传时使用
+	Watermark bool   // 转码时是否添加水印
+}
+
+type OssUploadInitReq struct {
 	Hash string
+	Name string
+	Size int64
+}
+
+type OssUploadPresignReq struct {
+	Hash        string
+	PartNumbers []int // 需要签名的分片序号，从1开始
+}
+
+type OssUploadCompleteReq struct {
+	Hash  string
+	Parts []OssUploadPart
+}
+
+type OssUploadPart struct {
+	PartNumber int
+	ETag       string
+}
+
+type ImportVideoReq struct {
+	Url       string
+	Vid       uint // 导入为已有视频的分P，为0时创建新视频
+	Watermark bool // 转码时是否添加水印
 }
 
 type ReviewListReq struct {
@@ -37,3 +66,9 @@ type SearchVideoReq struct {
 	PageSize int
 	KeyWords string
 }
+
+type RetranscodeReq struct {
+	ResourceIds []uint // 指定资源
+	PartitionId uint   // 指定分区，包含子分区
+	All         bool   // 全部视频
+}
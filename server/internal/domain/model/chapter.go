@@ -0,0 +1,14 @@
+package model
+
+import "gorm.io/gorm"
+
+type Chapter struct {
+	gorm.Model
+	ResourceID uint    `gorm:"comment:视频资源ID;not null;index"`
+	Start      float64 `gorm:"comment:开始时间(秒);not null"`
+	Title      string  `gorm:"type:varchar(50);comment:章节标题;not null"`
+}
+
+func (table *Chapter) TableName() string {
+	return "chapter"
+}
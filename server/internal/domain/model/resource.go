@@ -10,6 +10,8 @@ type Resource struct {
 	CodecName string  `gorm:"type:varchar(10);comment:视频编码名称"`
 	Duration  float64 `gorm:"comment:视频时长;default:0"`
 	Status    int     `gorm:"comment:审核状态;not null;index"`
+	Watermark bool    `gorm:"comment:转码时是否添加水印;default:false"`
+	Clips     string  `gorm:"type:text;comment:剪辑保留的片段"`
 }
 
 func (table *Resource) TableName() string {
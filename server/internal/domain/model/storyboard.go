@@ -0,0 +1,14 @@
+package model
+
+import "gorm.io/gorm"
+
+type Storyboard struct {
+	gorm.Model
+	ResourceID uint   `gorm:"comment:视频资源ID;not null;uniqueIndex"`
+	DirName    string `gorm:"type:varchar(20);comment:目录名称;"`
+	Content    string `gorm:"type:mediumtext;comment:缩略图WebVTT;"`
+}
+
+func (table *Storyboard) TableName() string {
+	return "storyboard"
+}
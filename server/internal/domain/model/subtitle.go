@@ -0,0 +1,17 @@
+package model
+
+import "gorm.io/gorm"
+
+type Subtitle struct {
+	gorm.Model
+	ResourceID uint   `gorm:"comment:视频资源ID;not null;index"`
+	Uid        uint   `gorm:"comment:所属用户;index"`
+	Lang       string `gorm:"type:varchar(20);comment:语言代码;not null"`
+	Name       string `gorm:"type:varchar(50);comment:显示名称"`
+	DirName    string `gorm:"type:varchar(20);comment:目录名称;"`
+	Content    string `gorm:"type:text;comment:字幕播放列表;"`
+}
+
+func (table *Subtitle) TableName() string {
+	return "subtitle"
+}
@@ -0,0 +1,19 @@
+package model
+
+import "gorm.io/gorm"
+
+type TranscodingLog struct {
+	gorm.Model
+	ResourceID uint   `gorm:"comment:视频资源ID;not null;index"`
+	TaskID     uint   `gorm:"comment:转码任务ID;index"`
+	Stage      string `gorm:"type:varchar(20);comment:执行阶段;"`
+	Target     string `gorm:"type:varchar(500);comment:输出目标;"`
+	Command    string `gorm:"type:text;comment:执行的命令;"`
+	Duration   int64  `gorm:"comment:耗时(毫秒);"`
+	ExitCode   int    `gorm:"comment:退出码;"`
+	Stderr     string `gorm:"type:text;comment:错误输出的末尾部分;"`
+}
+
+func (table *TranscodingLog) TableName() string {
+	return "transcoding_log"
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TranscodingTask struct {
+	gorm.Model
+	Vid             uint       `gorm:"comment:所属视频;index"`
+	ResourceID      uint       `gorm:"comment:视频资源ID;not null;index"`
+	DirName         string     `gorm:"type:varchar(20);comment:目录名称;"`
+	Status          int        `gorm:"comment:任务状态;not null;index"`
+	Retries         int        `gorm:"comment:已重试次数;default:0"`
+	NextRunAt       time.Time  `gorm:"comment:下次执行时间;index"`
+	Error           string     `gorm:"type:text;comment:失败原因;"`
+	Retranscode     bool       `gorm:"comment:是否为重新转码;default:false"`
+	Clip            bool       `gorm:"comment:是否为剪辑;default:false"`
+	Clips           string     `gorm:"type:text;comment:剪辑保留的片段，任务完成时写入资源;"`
+	OutputDirName   string     `gorm:"type:varchar(20);comment:输出目录名称，为空时与源目录相同;"`
+	ReplacedDirName string     `gorm:"type:varchar(20);comment:被替换的切片目录，等待清理;"`
+	Worker          string     `gorm:"type:varchar(100);comment:执行任务的工作进程;"`
+	HeartbeatAt     *time.Time `gorm:"comment:最后心跳时间;"`
+}
+
+func (table *TranscodingTask) TableName() string {
+	return "transcoding_task"
+}
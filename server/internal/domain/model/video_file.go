@@ -9,6 +9,9 @@ type VideoFile struct {
 	DirName      string `gorm:"type:varchar(20);comment:目录名称;index"`
 	Hash         string `gorm:"type:varchar(64);comment:文件hash;"`
 	ChunksCount  int    `gorm:"comment:分片数量;"`
+	Size         int64  `gorm:"comment:文件大小，tus上传时使用;default:0"`
+	Verified     bool   `gorm:"comment:文件已通过hash校验;default:false"`
+	UploadID     string `gorm:"type:varchar(255);comment:OSS分片上传ID，直传时使用;"`
 }
 
 func (table *VideoFile) TableName() string {
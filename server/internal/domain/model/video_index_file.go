@@ -4,10 +4,16 @@ import "gorm.io/gorm"
 
 type VideoIndexFile struct {
 	gorm.Model
-	ResourceID uint   `gorm:"index;comment:视频资源ID;"`
-	Quality    string `gorm:"comment:视频质量;"`
-	DirName    string `gorm:"type:varchar(20);comment:目录名称;"`
-	Content    string `gorm:"type:text;comment:文件内容;"`
+	ResourceID uint    `gorm:"index;comment:视频资源ID;"`
+	Quality    string  `gorm:"comment:视频质量;"`
+	DirName    string  `gorm:"type:varchar(20);comment:目录名称;"`
+	Content    string  `gorm:"type:text;comment:文件内容;"`
+	Bandwidth  int     `gorm:"comment:峰值码率;default:0"`
+	Width      int     `gorm:"comment:视频宽度;default:0"`
+	Height     int     `gorm:"comment:视频高度;default:0"`
+	Codecs     string  `gorm:"type:varchar(100);comment:编码格式;"`
+	FrameRate  float64 `gorm:"comment:帧率;default:0"`
+	StartPts   *int    `gorm:"comment:首个切片的起始时间戳(90kHz)，为空时未知;"`
 }
 
 func (table *VideoIndexFile) TableName() string {
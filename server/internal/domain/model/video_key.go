@@ -0,0 +1,15 @@
+package model
+
+import "gorm.io/gorm"
+
+type VideoKey struct {
+	gorm.Model
+	ResourceID uint   `gorm:"comment:视频资源ID;not null;uniqueIndex:idx_video_key_resource_dir"`
+	DirName    string `gorm:"type:varchar(20);comment:目录名称;not null;uniqueIndex:idx_video_key_resource_dir"`
+	Secret     string `gorm:"type:varchar(32);comment:AES-128密钥(hex);not null"`
+	IV         string `gorm:"type:varchar(32);comment:初始向量(hex);not null"`
+}
+
+func (table *VideoKey) TableName() string {
+	return "video_key"
+}
@@ -21,8 +21,6 @@ type StorageConfigResp struct {
 	Region   string `json:"region"`
 	Domain   string `json:"domain"`
 	Private  bool   `json:"private"`
-
-	UploadMp4File bool `json:"uploadMp4File"`
 }
 
 type OtherConfigResp struct {
@@ -31,3 +29,35 @@ type OtherConfigResp struct {
 	Generate1080p60 bool   `json:"generate1080p60"`
 	UseGpu          bool   `json:"useGpu"`
 }
+
+type TranscodingConfigResp struct {
+	EmbeddedWorker bool                  `json:"embeddedWorker"`
+	WorkerCount    int                   `json:"workerCount"`
+	MaxRetry       int                   `json:"maxRetry"`
+	Packaging      string                `json:"packaging"`
+	Encrypt        bool                  `json:"encrypt"`
+	Loudnorm       bool                  `json:"loudnorm"`
+	TargetLufs     float64               `json:"targetLufs"`
+	Ladder         []TranscodingRungResp `json:"ladder"`
+	Watermark      WatermarkResp         `json:"watermark"`
+}
+
+type TranscodingRungResp struct {
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Bitrate      string `json:"bitrate"`
+	MaxFps       int    `json:"maxFps"`
+	Codec        string `json:"codec"`
+	Crf          int    `json:"crf"`
+	Preset       string `json:"preset"`
+	AudioBitrate string `json:"audioBitrate"`
+}
+
+type WatermarkResp struct {
+	Image    string  `json:"image"`
+	Text     bool    `json:"text"`
+	Position string  `json:"position"`
+	Opacity  float64 `json:"opacity"`
+	Scale    float64 `json:"scale"`
+	FontFile string  `json:"fontFile"`
+}
@@ -13,6 +13,12 @@ type ResourceResp struct {
 	Title     string    `json:"title"`
 	Duration  float64   `json:"duration"`
 	Status    int       `json:"status"`
+
+	Progress   []TranscodingProgressResp `json:"progress,omitempty" gorm:"-"`
+	Subtitles  []SubtitleResp            `json:"subtitles,omitempty" gorm:"-"`
+	Storyboard string                    `json:"storyboard,omitempty" gorm:"-"`
+	Chapters   []ChapterResp             `json:"chapters,omitempty" gorm:"-"`
+	ChapterVTT string                    `json:"chapterVtt,omitempty" gorm:"-"`
 }
 
 func ResourceToResourceResp(resource model.Resource) ResourceResp {
@@ -25,3 +31,28 @@ func ResourceToResourceResp(resource model.Resource) ResourceResp {
 		Status:    resource.Status,
 	}
 }
+
+type SubtitleResp struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ResourceID uint      `json:"resourceId"`
+	Lang       string    `json:"lang"`
+	Name       string    `json:"name"`
+}
+
+type ChapterResp struct {
+	Start float64 `json:"start"`
+	Title string  `json:"title"`
+}
+
+// URL导入视频进度
+type VideoImportResp struct {
+	ID       string        `json:"id"`
+	Uid      uint          `json:"uid"`
+	Status   string        `json:"status"`
+	Received int64         `json:"received"`
+	Total    int64         `json:"total"` // 服务器没有返回文件大小时为0
+	Message  string        `json:"message"`
+	Vid      uint          `json:"vid"`
+	Resource *ResourceResp `json:"resource"`
+}
@@ -0,0 +1,42 @@
+package vo
+
+import "time"
+
+type TranscodingProgressResp struct {
+	Target  string  `json:"target"`
+	Percent float64 `json:"percent"`
+	Eta     int     `json:"eta"` // 预计剩余时间(秒)
+}
+
+// 推送给上传者的转码进度消息
+type TranscodingProgressMsg struct {
+	VideoID    uint                      `json:"videoId"`
+	ResourceID uint                      `json:"resourceId"`
+	Status     int                       `json:"status"`
+	Progress   []TranscodingProgressResp `json:"progress"`
+}
+
+type TranscodingTaskResp struct {
+	ID          uint      `json:"id"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	Vid         uint      `json:"vid"`
+	ResourceID  uint      `json:"resourceId"`
+	Status      int       `json:"status"`
+	Retries     int       `json:"retries"`
+	Error       string    `json:"error"`
+	Retranscode bool      `json:"retranscode"`
+	Worker      string    `json:"worker"`
+}
+
+type TranscodingLogResp struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	TaskID    uint      `json:"taskId"`
+	Stage     string    `json:"stage"`
+	Target    string    `json:"target"`
+	Command   string    `json:"command"`
+	Duration  int64     `json:"duration"`
+	ExitCode  int       `json:"exitCode"`
+	Stderr    string    `json:"stderr"`
+}
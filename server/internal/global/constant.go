@@ -22,6 +22,46 @@ const (
 	PROCESSING_FAIL = 3000
 )
 
+// 转码任务状态
+const (
+	// 排队中
+	TRANSCODING_QUEUED = 0
+	// 转码中
+	TRANSCODING_RUNNING = 1
+	// 转码完成
+	TRANSCODING_DONE = 2
+	// 转码失败
+	TRANSCODING_FAILED = 3
+	// 已取消
+	TRANSCODING_CANCELLED = 4
+	// 等待源文件上传到OSS，上传完成后排队
+	TRANSCODING_UPLOADING = 5
+)
+
+// 转码封装格式
+const (
+	// MPEG-TS切片
+	PACKAGING_TS = "ts"
+	// CMAF fMP4切片
+	PACKAGING_CMAF = "cmaf"
+)
+
+// 水印位置
+const (
+	WATERMARK_TOP_LEFT     = "top_left"
+	WATERMARK_TOP_RIGHT    = "top_right"
+	WATERMARK_BOTTOM_LEFT  = "bottom_left"
+	WATERMARK_BOTTOM_RIGHT = "bottom_right"
+)
+
+// 章节导入来源
+const (
+	// 视频简介中的时间戳
+	CHAPTER_SOURCE_DESC = "desc"
+	// 视频文件中的章节信息
+	CHAPTER_SOURCE_FILE = "file"
+)
+
 // 用户关系
 const (
 	// 未关注
@@ -1,20 +1,54 @@
 package global
 
 type VideoInfo struct {
-	Stream []Streams `json:"streams"`
-	Format Format    `json:"format"`
+	Stream   []Streams  `json:"streams"`
+	Format   Format     `json:"format"`
+	Chapters []Chapters `json:"chapters"`
 }
 
 type Streams struct {
-	CodecName    string `json:"codec_name"`
-	Width        int    `json:"width,omitempty"`
-	Height       int    `json:"height,omitempty"`
-	PixFmt       string `json:"pix_fmt,omitempty"`
-	Duration     string `json:"duration"`
-	RFrameRate   string `json:"r_frame_rate,omitempty"`  // 原始帧率 (如 "30/1")
-	AvgFrameRate string `json:"avg_frame_rate,omitempty"` // 平均帧率 (如 "30/1")
+	Index            int          `json:"index"`
+	CodecName        string       `json:"codec_name"`
+	CodecType        string       `json:"codec_type"`
+	Profile          string       `json:"profile,omitempty"`
+	Level            int          `json:"level,omitempty"`
+	Width            int          `json:"width,omitempty"`
+	Height           int          `json:"height,omitempty"`
+	PixFmt           string       `json:"pix_fmt,omitempty"`
+	ColorTransfer    string       `json:"color_transfer,omitempty"`
+	BitsPerRawSample string       `json:"bits_per_raw_sample,omitempty"`
+	Duration         string       `json:"duration"`
+	RFrameRate       string       `json:"r_frame_rate,omitempty"`   // 原始帧率 (如 "30/1")
+	AvgFrameRate     string       `json:"avg_frame_rate,omitempty"` // 平均帧率 (如 "30/1")
+	Disposition      Disposition  `json:"disposition"`
+	Tags             StreamTags   `json:"tags"`
+	SideDataList     []StreamSide `json:"side_data_list,omitempty"`
+}
+
+type Disposition struct {
+	AttachedPic int `json:"attached_pic"` // 封面图片
+}
+
+type StreamTags struct {
+	Rotate string `json:"rotate,omitempty"` // 旧版ffprobe的旋转信息
+}
+
+type StreamSide struct {
+	SideDataType string `json:"side_data_type"`
+	Rotation     int    `json:"rotation,omitempty"` // Display Matrix 中的旋转角度
 }
 
 type Format struct {
-	BitRate string `json:"bit_rate"`
+	StartTime string `json:"start_time"`
+	Duration  string `json:"duration"`
+	BitRate   string `json:"bit_rate"`
+}
+
+type Chapters struct {
+	StartTime string      `json:"start_time"`
+	Tags      ChapterTags `json:"tags"`
+}
+
+type ChapterTags struct {
+	Title string `json:"title,omitempty"`
 }
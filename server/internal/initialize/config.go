@@ -2,6 +2,7 @@ package initialize
 
 import (
 	"github.com/spf13/viper"
+	"interastral-peace.com/alnitak/internal/config"
 	"interastral-peace.com/alnitak/internal/global"
 	"interastral-peace.com/alnitak/utils"
 )
@@ -32,6 +33,17 @@ func getConfigFileName(env string) string {
 	}
 }
 
+// 默认转码阶梯，max_fps大于30的档位只在开启高帧率且源视频满足时生成
+var defaultTranscodingLadder = []config.TranscodingRung{
+	{Width: 3840, Height: 2160, Bitrate: "16000k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "192k"},
+	{Width: 2560, Height: 1440, Bitrate: "9000k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "192k"},
+	{Width: 1920, Height: 1080, Bitrate: "6000k", MaxFps: 60, Codec: "libx264", Crf: 20, AudioBitrate: "128k"},
+	{Width: 1920, Height: 1080, Bitrate: "3000k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "128k"},
+	{Width: 1280, Height: 720, Bitrate: "2000k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "128k"},
+	{Width: 854, Height: 480, Bitrate: "900k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "96k"},
+	{Width: 640, Height: 360, Bitrate: "500k", MaxFps: 30, Codec: "libx264", Crf: 20, AudioBitrate: "96k"},
+}
+
 // 初始化默认配置
 func initDefaultConfigItems() {
 	if viper.GetString("security.access_jwt_secret") == "" {
@@ -40,6 +52,54 @@ func initDefaultConfigItems() {
 	if viper.GetString("security.refresh_jwt_secret") == "" {
 		viper.Set("security.refresh_jwt_secret", utils.GenerateNumberCode(16))
 	}
+	if !viper.IsSet("file.import.timeout") {
+		viper.Set("file.import.timeout", 30)
+	}
+	if !viper.IsSet("file.import.allow_private") {
+		viper.Set("file.import.allow_private", false)
+	}
+	if !viper.IsSet("transcoding.embedded_worker") {
+		viper.Set("transcoding.embedded_worker", true)
+	}
+	if !viper.IsSet("transcoding.worker_count") {
+		viper.Set("transcoding.worker_count", 2)
+	}
+	if !viper.IsSet("transcoding.max_retry") {
+		viper.Set("transcoding.max_retry", 3)
+	}
+	if !viper.IsSet("transcoding.packaging") {
+		viper.Set("transcoding.packaging", global.PACKAGING_TS)
+	}
+	if !viper.IsSet("transcoding.encrypt") {
+		viper.Set("transcoding.encrypt", false)
+	}
+	if !viper.IsSet("transcoding.loudnorm") {
+		viper.Set("transcoding.loudnorm", false)
+	}
+	if !viper.IsSet("transcoding.target_lufs") {
+		viper.Set("transcoding.target_lufs", -16)
+	}
+	if !viper.IsSet("transcoding.ladder") {
+		viper.Set("transcoding.ladder", defaultTranscodingLadder)
+	}
+	if !viper.IsSet("transcoding.watermark.image") {
+		viper.Set("transcoding.watermark.image", "")
+	}
+	if !viper.IsSet("transcoding.watermark.text") {
+		viper.Set("transcoding.watermark.text", false)
+	}
+	if !viper.IsSet("transcoding.watermark.position") {
+		viper.Set("transcoding.watermark.position", global.WATERMARK_BOTTOM_RIGHT)
+	}
+	if !viper.IsSet("transcoding.watermark.opacity") {
+		viper.Set("transcoding.watermark.opacity", 0.8)
+	}
+	if !viper.IsSet("transcoding.watermark.scale") {
+		viper.Set("transcoding.watermark.scale", 0.12)
+	}
+	if !viper.IsSet("transcoding.watermark.font_file") {
+		viper.Set("transcoding.watermark.font_file", "")
+	}
 
 	viper.WriteConfig()
 }
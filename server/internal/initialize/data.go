@@ -7,12 +7,14 @@ import (
 )
 
 func InitDefaultData() {
-	initApiData()        // 初始化API数据
-	initCasbinRuleData() // 初始化CasbinRule数据
-	initMenuData()       // 初始化菜单数据
-	initPartitionData()  // 初始化分区数据
-	initRoleData()       // 初始化角色数据
-	initUserData()       // 初始化用户数据
+	initApiData()           // 初始化API数据
+	initCasbinRuleData()    // 初始化CasbinRule数据
+	upgradeApiData()        // 补充新版本的API数据
+	upgradeCasbinRuleData() // 补充新版本的CasbinRule数据
+	initMenuData()          // 初始化菜单数据
+	initPartitionData()     // 初始化分区数据
+	initRoleData()          // 初始化角色数据
+	initUserData()          // 初始化用户数据
 }
 
 // 初始化API数据
@@ -340,6 +342,108 @@ func initCasbinRuleData() {
 	}
 }
 
+// 新版本增加的API，已有数据的旧版本启动时同样补充
+var upgradeApis = []model.Api{
+	{Method: "POST", Path: "/api/v1/resource/uploadSubtitle", Category: "资源", Desc: "上传字幕"},
+	{Method: "DELETE", Path: "/api/v1/resource/deleteSubtitle/:id", Category: "资源", Desc: "删除字幕"},
+	{Method: "GET", Path: "/api/v1/resource/getSubtitleList", Category: "资源", Desc: "获取字幕列表"},
+	{Method: "POST", Path: "/api/v1/resource/clipResource", Category: "资源", Desc: "剪辑视频资源"},
+	{Method: "POST", Path: "/api/v1/resource/setChapters", Category: "资源", Desc: "设置章节"},
+	{Method: "POST", Path: "/api/v1/resource/importChapters", Category: "资源", Desc: "导入章节"},
+	{Method: "POST", Path: "/api/v1/upload/tus", Category: "上传", Desc: "创建tus上传"},
+	{Method: "HEAD", Path: "/api/v1/upload/tus/:id", Category: "上传", Desc: "获取tus上传进度"},
+	{Method: "PATCH", Path: "/api/v1/upload/tus/:id", Category: "上传", Desc: "tus上传文件数据"},
+	{Method: "DELETE", Path: "/api/v1/upload/tus/:id", Category: "上传", Desc: "终止tus上传"},
+	{Method: "POST", Path: "/api/v1/upload/oss/init", Category: "上传", Desc: "初始化OSS直传"},
+	{Method: "POST", Path: "/api/v1/upload/oss/presign", Category: "上传", Desc: "获取OSS分片上传地址"},
+	{Method: "POST", Path: "/api/v1/upload/oss/complete", Category: "上传", Desc: "完成OSS直传"},
+	{Method: "POST", Path: "/api/v1/upload/oss/abort", Category: "上传", Desc: "取消OSS直传"},
+	{Method: "POST", Path: "/api/v1/upload/importVideo", Category: "上传", Desc: "从URL导入视频"},
+	{Method: "GET", Path: "/api/v1/upload/importVideo", Category: "上传", Desc: "获取视频导入进度"},
+	{Method: "GET", Path: "/api/v1/video/getTranscodingLogList", Category: "视频", Desc: "获取资源的转码日志（后台管理）"},
+	{Method: "GET", Path: "/api/v1/video/getMasterFileManage", Category: "视频", Desc: "获取主播放列表（后台管理）"},
+	{Method: "GET", Path: "/api/v1/video/getMpdFileManage", Category: "视频", Desc: "获取DASH描述文件（后台管理）"},
+	{Method: "GET", Path: "/api/v1/video/getSubtitleFileManage", Category: "视频", Desc: "获取字幕播放列表（后台管理）"},
+	{Method: "POST", Path: "/api/v1/video/getTranscodingTaskListManage", Category: "视频", Desc: "获取转码任务列表（后台管理）"},
+	{Method: "POST", Path: "/api/v1/video/retranscodeManage", Category: "视频", Desc: "重新转码（后台管理）"},
+	{Method: "GET", Path: "/api/v1/config/getTranscodingConfig", Category: "配置", Desc: "获取转码配置（后台管理）"},
+	{Method: "POST", Path: "/api/v1/config/setTranscodingConfig", Category: "配置", Desc: "编辑转码配置（后台管理）"},
+}
+
+// 新版本增加的CasbinRule，已有数据的旧版本启动时同样补充
+var upgradeCasbinRules = []model.CasbinRule{
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/uploadSubtitle", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/deleteSubtitle/:id", V2: "DELETE"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/getSubtitleList", V2: "GET"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/clipResource", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/setChapters", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/resource/importChapters", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/tus", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/tus/:id", V2: "HEAD"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/tus/:id", V2: "PATCH"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/tus/:id", V2: "DELETE"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/oss/init", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/oss/presign", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/oss/complete", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/oss/abort", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/importVideo", V2: "POST"},
+	{Ptype: "p", V0: "001", V1: "/api/v1/upload/importVideo", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/config/getTranscodingConfig", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/config/setTranscodingConfig", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/uploadSubtitle", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/deleteSubtitle/:id", V2: "DELETE"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/getSubtitleList", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/clipResource", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/setChapters", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/resource/importChapters", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/tus", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/tus/:id", V2: "HEAD"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/tus/:id", V2: "PATCH"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/tus/:id", V2: "DELETE"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/oss/init", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/oss/presign", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/oss/complete", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/oss/abort", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/importVideo", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/upload/importVideo", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/getTranscodingLogList", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/getMasterFileManage", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/getMpdFileManage", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/getSubtitleFileManage", V2: "GET"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/getTranscodingTaskListManage", V2: "POST"},
+	{Ptype: "p", V0: "002", V1: "/api/v1/video/retranscodeManage", V2: "POST"},
+}
+
+// 补充缺少的API数据，被删除的API不会重新添加
+func upgradeApiData() {
+	for _, api := range upgradeApis {
+		var total int64
+		global.Mysql.Unscoped().Model(&model.Api{}).Where("method = ? and path = ?", api.Method, api.Path).Count(&total)
+		if total > 0 {
+			continue
+		}
+
+		if err := global.Mysql.Create(&api).Error; err != nil {
+			zap.L().Error("API数据补充失败", zap.String("err", err.Error()), zap.String("module", "initialize"))
+		}
+	}
+}
+
+// 补充缺少的CasbinRule数据
+func upgradeCasbinRuleData() {
+	for _, rule := range upgradeCasbinRules {
+		var total int64
+		global.Mysql.Model(&model.CasbinRule{}).Where("ptype = ? and v0 = ? and v1 = ? and v2 = ?", rule.Ptype, rule.V0, rule.V1, rule.V2).Count(&total)
+		if total > 0 {
+			continue
+		}
+
+		if err := global.Mysql.Create(&rule).Error; err != nil {
+			zap.L().Error("CasbinRule数据补充失败", zap.String("err", err.Error()), zap.String("module", "initialize"))
+		}
+	}
+}
+
 // 初始化菜单数据
 func initMenuData() {
 	var total int64
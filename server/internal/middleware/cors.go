@@ -12,11 +12,14 @@ func CORS() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Writer.Header().Set("Access-Control-Allow-Origin", getAllowOrigin(ctx.GetHeader("Origin")))
 		ctx.Writer.Header().Set("Access-Control-Max-Age", "86400")
-		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "authorization,Authorization,DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type")
+		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS,HEAD,PATCH")
+		ctx.Writer.Header().Set("Access-Control-Allow-Headers", "authorization,Authorization,DNT,X-CustomHeader,Keep-Alive,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,"+
+			"Tus-Resumable,Upload-Length,Upload-Offset,Upload-Metadata,Upload-Checksum")
+		ctx.Writer.Header().Set("Access-Control-Expose-Headers", "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Tus-Checksum-Algorithm,Upload-Offset,Upload-Length")
 		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 
-		if ctx.Request.Method == http.MethodOptions {
+		// 只拦截跨域预检请求，其他OPTIONS请求交给路由处理，如tus协议信息
+		if ctx.Request.Method == http.MethodOptions && ctx.GetHeader("Access-Control-Request-Method") != "" {
 			ctx.AbortWithStatus(200)
 		} else {
 			ctx.Next()
@@ -31,7 +31,10 @@ func OperationRecord() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var body []byte
 		var userId int
-		if c.Request.Method != http.MethodGet {
+		if c.ContentType() == "application/offset+octet-stream" {
+			// tus上传的文件数据不读取到内存
+			body = []byte("[文件]")
+		} else if c.Request.Method != http.MethodGet {
 			var err error
 			body, err = io.ReadAll(c.Request.Body)
 			if err != nil {
@@ -15,6 +15,8 @@ type Response struct {
 const (
 	ERROR   = 500
 	SUCCESS = 200
+
+	INVALID_MEDIA = 4001 // 无效的媒体文件
 )
 
 func Result(c *gin.Context, code int, data interface{}, msg string) {
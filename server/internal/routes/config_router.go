@@ -24,6 +24,10 @@ func CollectConfigRoutes(r *gin.RouterGroup) {
 		configAuth.GET("getOtherConfig", api.GetOtherConfig)
 		// 修改其他配置
 		configAuth.POST("setOtherConfig", api.SetOtherConfig)
+		// 获取转码配置
+		configAuth.GET("getTranscodingConfig", api.GetTranscodingConfig)
+		// 修改转码配置
+		configAuth.POST("setTranscodingConfig", api.SetTranscodingConfig)
 	}
 
 }
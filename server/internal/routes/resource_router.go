@@ -14,5 +14,11 @@ func CollectResourceRoutes(r *gin.RouterGroup) {
 	{
 		resourceAuth.PUT("modifyTitle", api.ModifyResourceTitle)
 		resourceAuth.DELETE("deleteResource/:id", api.DeleteResource)
+		resourceAuth.POST("uploadSubtitle", api.UploadSubtitle)
+		resourceAuth.DELETE("deleteSubtitle/:id", api.DeleteSubtitle)
+		resourceAuth.GET("getSubtitleList", api.GetSubtitleList)
+		resourceAuth.POST("clipResource", api.ClipResource)
+		resourceAuth.POST("setChapters", api.SetChapters)
+		resourceAuth.POST("importChapters", api.ImportChapters)
 	}
 }
@@ -17,5 +17,24 @@ func CollectUploadRoutes(r *gin.RouterGroup) {
 		uploadGroup.POST("checkVideo", api.UploadVideoCheck)
 		uploadGroup.POST("chunkVideo", api.UploadVideoChunk) // 分片上传视频
 		uploadGroup.POST("mergeVideo", api.UploadVideoMerge) // 合并视频分片
+
+		// tus断点续传协议
+		uploadGroup.POST("tus", api.TusCreateUpload)
+		uploadGroup.HEAD("tus/:id", api.TusGetUpload)
+		uploadGroup.PATCH("tus/:id", api.TusPatchUpload)
+		uploadGroup.DELETE("tus/:id", api.TusTerminateUpload)
+
+		// OSS直传
+		uploadGroup.POST("oss/init", api.OssUploadInit)
+		uploadGroup.POST("oss/presign", api.OssUploadPresign)
+		uploadGroup.POST("oss/complete", api.OssUploadComplete)
+		uploadGroup.POST("oss/abort", api.OssUploadAbort)
+
+		// 从URL导入视频
+		uploadGroup.POST("importVideo", api.ImportVideo)
+		uploadGroup.GET("importVideo", api.GetVideoImport)
 	}
+
+	// tus协议信息，不需要登录
+	r.OPTIONS("upload/tus", api.TusOptions)
 }
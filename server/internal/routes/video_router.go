@@ -28,6 +28,8 @@ func CollectVideoRoutes(r *gin.RouterGroup) {
 		videoAuth.POST("getReviewList", api.GetReviewList)
 		// 获取审核资源列表（后台管理）
 		videoAuth.GET("getReviewResourceList", api.GetReviewResourceList)
+		// 获取资源的转码日志
+		videoAuth.GET("getTranscodingLogList", api.GetTranscodingLogList)
 		// 获取视频列表（后台管理）
 		videoAuth.POST("getVideoListManage", api.GetVideoListManage)
 		// 删除视频（后台管理）
@@ -36,16 +38,41 @@ func CollectVideoRoutes(r *gin.RouterGroup) {
 		videoAuth.GET("getResourceQualityManage", api.GetResourceQualityManage)
 		// 获取视频文件（后台管理）
 		videoAuth.GET("getVideoFileManage", api.GetVideoFileManage)
+		// 获取主播放列表（后台管理）
+		videoAuth.GET("getMasterFileManage", api.GetVideoMasterFileManage)
+		// 获取DASH描述文件（后台管理）
+		videoAuth.GET("getMpdFileManage", api.GetVideoMpdFileManage)
+		// 获取字幕播放列表（后台管理）
+		videoAuth.GET("getSubtitleFileManage", api.GetSubtitleFileManage)
+		// 获取转码任务列表（后台管理）
+		videoAuth.POST("getTranscodingTaskListManage", api.GetTranscodingTaskListManage)
+		// 重新转码（后台管理）
+		videoAuth.POST("retranscodeManage", api.RetranscodeManage)
 	}
 
+	// 转码进度Websocket连接
+	videoGroup.GET("transcodingProgress", middleware.WsAuth(), api.GetTranscodingProgressConnect)
+
 	// 获取视频信息
 	videoGroup.GET("getVideoById", api.GetVideoById)
 	// 获取视频资源支持的分辨率信息
 	videoGroup.GET("getResourceQuality", api.GetResourceQuality)
 	// 获取视频文件
 	videoGroup.GET("getVideoFile", api.GetVideoFile)
+	// 获取主播放列表
+	videoGroup.GET("getMasterFile", api.GetVideoMasterFile)
+	// 获取DASH描述文件
+	videoGroup.GET("getMpdFile", api.GetVideoMpdFile)
+	// 获取字幕播放列表
+	videoGroup.GET("getSubtitleFile", api.GetSubtitleFile)
+	// 获取缩略图WebVTT
+	videoGroup.GET("getStoryboard", api.GetStoryboard)
+	// 获取章节WebVTT
+	videoGroup.GET("getChapterFile", api.GetChapterFile)
 	// 获取视频切片
 	videoGroup.GET("slice/:file", api.GetVideoSlice)
+	// 获取切片解密密钥
+	videoGroup.GET("key", api.GetVideoKey)
 	// 获取用户视频
 	videoGroup.GET("getVideoByUser", api.GetVideoByUser)
 	// 获取热门视频
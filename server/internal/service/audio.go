@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	AUDIO_QUALITY           = "audio" // 纯音频流的质量名称
+	AUDIO_RENDITION_BITRATE = "128k"  // 纯音频流码率
+	LOUDNORM_TRUE_PEAK      = -1.5    // 真峰值上限(dBTP)
+	LOUDNORM_LRA            = 11      // 响度范围(LU)
+)
+
+// loudnorm第一遍测量的结果
+type loudnormStats struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// 生成响度标准化滤镜，使用两遍loudnorm以获得线性标准化
+func getLoudnormFilter(ctx context.Context, inputFile string, clips []dto.ClipRange, targetLufs float64, logger *transcodingLogger) (string, error) {
+	stats, err := measureLoudness(ctx, inputFile, clips, targetLufs, logger)
+	if err != nil {
+		return "", err
+	}
+
+	// 第二遍使用测量值，输出后重新采样到48kHz
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%d:measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true,aresample=48000",
+		targetLufs, LOUDNORM_TRUE_PEAK, LOUDNORM_LRA, stats.InputI, stats.InputTP, stats.InputLRA, stats.InputThresh, stats.TargetOffset,
+	), nil
+}
+
+// 测量音频响度，有剪辑时只测量保留的片段
+func measureLoudness(ctx context.Context, inputFile string, clips []dto.ClipRange, targetLufs float64, logger *transcodingLogger) (*loudnormStats, error) {
+	filter := fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%d:print_format=json", targetLufs, LOUDNORM_TRUE_PEAK, LOUDNORM_LRA)
+	command := []string{"-hide_banner", "-nostats", "-i", inputFile}
+	if len(clips) > 0 {
+		command = append(command, clipFilterArgs("[0:a:0]", clips, true, filter)...)
+	} else {
+		command = append(command, "-map", "0:a:0", "-af", filter)
+	}
+	command = append(command, "-f", "null", "-")
+
+	// loudnorm的结果输出在stderr的末尾
+	cmd := exec.CommandContext(ctx, "ffmpeg", command...)
+	utils.SetProcessGroup(cmd)
+	startAt := time.Now()
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		logger.record(TRANSCODING_STAGE_LOUDNORM, AUDIO_QUALITY, cmd, startAt, string(out), err)
+		utils.ErrorLog("测量音频响度失败", "transcoding", string(out))
+		return nil, err
+	}
+	logger.record(TRANSCODING_STAGE_LOUDNORM, AUDIO_QUALITY, cmd, startAt, string(out), nil)
+
+	output := string(out)
+	start := strings.LastIndex(output, "{")
+	end := strings.LastIndex(output, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("读取音频响度失败")
+	}
+
+	var stats loudnormStats
+	if err := json.Unmarshal([]byte(output[start:end+1]), &stats); err != nil {
+		return nil, err
+	}
+
+	// 静音的音频无法标准化
+	if stats.InputI == "-inf" || stats.InputTP == "-inf" {
+		return nil, errors.New("音频为静音")
+	}
+
+	return &stats, nil
+}
+
+// 获取音频滤镜，未开启响度标准化或测量失败时返回空
+func getAudioFilter(ctx context.Context, inputFile string, clips []dto.ClipRange, logger *transcodingLogger) string {
+	if !global.Config.Transcoding.Loudnorm {
+		return ""
+	}
+
+	filter, err := getLoudnormFilter(ctx, inputFile, clips, global.Config.Transcoding.TargetLufs, logger)
+	if err != nil {
+		utils.ErrorLog("响度标准化失败，使用原始音量", "transcoding", err.Error())
+		return ""
+	}
+
+	return filter
+}
@@ -0,0 +1,377 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	CHAPTER_MAX_COUNT = 100 // 每个资源最多的章节数量
+	CHAPTER_TITLE_MAX = 50  // 章节标题最大长度
+)
+
+// 简介中的章节时间戳，如 "00:00 开头"、"1:02:03 - 结尾"
+var chapterDescRegexp = regexp.MustCompile(`^\s*(?:(\d{1,2}):)?(\d{1,2}):(\d{2})\s*[-–—|:：]?\s*(.+)$`)
+
+// 设置章节，覆盖原有章节
+func SetChapters(ctx *gin.Context, setChaptersReq dto.SetChaptersReq) ([]vo.ChapterResp, error) {
+	resource, err := getUserResource(ctx, setChaptersReq.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	chapters, err := verifyChapters(setChaptersReq.Chapters, resource.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := saveChapters(resource, chapters); err != nil {
+		return nil, err
+	}
+
+	return chaptersToChapterResp(chapters), nil
+}
+
+// 从视频简介或视频文件导入章节
+func ImportChapters(ctx *gin.Context, importChaptersReq dto.ImportChaptersReq) ([]vo.ChapterResp, error) {
+	resource, err := getUserResource(ctx, importChaptersReq.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var chapters []dto.Chapter
+	switch importChaptersReq.Source {
+	case global.CHAPTER_SOURCE_DESC:
+		video, err := FindVideoById(resource.Vid)
+		if err != nil {
+			return nil, errors.New("视频不存在")
+		}
+		chapters = parseDescChapters(video.Desc)
+	case global.CHAPTER_SOURCE_FILE:
+		chapters, err = getFileChapters(resource)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("导入来源有误")
+	}
+
+	chapters = filterChapters(chapters, resource.Duration)
+	if len(chapters) == 0 {
+		return nil, errors.New("没有找到章节信息")
+	}
+
+	if err := saveChapters(resource, chapters); err != nil {
+		return nil, err
+	}
+
+	return chaptersToChapterResp(chapters), nil
+}
+
+// 获取章节WebVTT
+func GetChapterFile(ctx *gin.Context, resourceId uint) (string, error) {
+	var resource model.Resource
+	global.Mysql.Model(&model.Resource{}).Where("id = ? and `status` = ?", resourceId, global.AUDIT_APPROVED).First(&resource)
+	if resource.ID == 0 {
+		return "", errors.New("资源不存在")
+	}
+
+	var chapters []model.Chapter
+	global.Mysql.Where("resource_id = ?", resourceId).Order("start").Find(&chapters)
+	if len(chapters) == 0 {
+		return "", errors.New("章节不存在")
+	}
+
+	return buildChapterVTT(chapters, resource.Duration), nil
+}
+
+// 获取当前用户的资源
+func getUserResource(ctx *gin.Context, resourceId uint) (model.Resource, error) {
+	var resource model.Resource
+	userId := ctx.GetUint("userId")
+	global.Mysql.Model(&model.Resource{}).Where("id = ? and uid = ?", resourceId, userId).First(&resource)
+	if resource.ID == 0 {
+		return resource, errors.New("资源不存在")
+	}
+
+	return resource, nil
+}
+
+// 校验手动设置的章节
+func verifyChapters(chapters []dto.Chapter, duration float64) ([]dto.Chapter, error) {
+	if len(chapters) > CHAPTER_MAX_COUNT {
+		return nil, errors.New("章节数量过多")
+	}
+
+	result := make([]dto.Chapter, len(chapters))
+	for i, c := range chapters {
+		c.Title = strings.TrimSpace(c.Title)
+		if c.Title == "" || utf8.RuneCountInString(c.Title) > CHAPTER_TITLE_MAX {
+			return nil, errors.New("章节标题长度有误")
+		}
+		if c.Title != sanitizeChapterTitle(c.Title) {
+			return nil, errors.New("章节标题不能包含换行或-->")
+		}
+		if c.Start < 0 || (duration > 0 && c.Start >= duration) {
+			return nil, errors.New("章节时间超出视频时长")
+		}
+		result[i] = c
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Start < result[j].Start
+	})
+	for i := 1; i < len(result); i++ {
+		if result[i].Start == result[i-1].Start {
+			return nil, errors.New("章节时间重复")
+		}
+	}
+
+	return result, nil
+}
+
+// 过滤导入的章节，去掉无效的章节并截断过长的标题
+func filterChapters(chapters []dto.Chapter, duration float64) []dto.Chapter {
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+
+	result := make([]dto.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		c.Title = sanitizeChapterTitle(c.Title)
+		if c.Title == "" || c.Start < 0 || (duration > 0 && c.Start >= duration) {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1].Start == c.Start {
+			continue
+		}
+		if utf8.RuneCountInString(c.Title) > CHAPTER_TITLE_MAX {
+			c.Title = string([]rune(c.Title)[:CHAPTER_TITLE_MAX])
+		}
+		result = append(result, c)
+		if len(result) == CHAPTER_MAX_COUNT {
+			break
+		}
+	}
+
+	return result
+}
+
+// 去掉标题中的换行及WebVTT时间分隔符，避免写入WebVTT时产生额外的章节
+func sanitizeChapterTitle(title string) string {
+	title = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ", "-->", "->").Replace(title)
+	return strings.TrimSpace(title)
+}
+
+// 解析简介中的时间戳
+func parseDescChapters(desc string) []dto.Chapter {
+	chapters := make([]dto.Chapter, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(desc, "\r\n", "\n"), "\n") {
+		matches := chapterDescRegexp.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		hours, _ := strconv.Atoi(matches[1])
+		minutes, _ := strconv.Atoi(matches[2])
+		seconds, _ := strconv.Atoi(matches[3])
+		if seconds >= 60 || (matches[1] != "" && minutes >= 60) {
+			continue
+		}
+
+		chapters = append(chapters, dto.Chapter{
+			Start: float64(hours*3600 + minutes*60 + seconds),
+			Title: matches[4],
+		})
+	}
+
+	return chapters
+}
+
+// 读取源视频文件中的章节，并转换为剪辑后的时间
+func getFileChapters(resource model.Resource) ([]dto.Chapter, error) {
+	sourceDirName := getSourceDirName(resource.ID)
+	if sourceDirName == "" {
+		return nil, errors.New("源视频文件不存在")
+	}
+	if err := fetchSourceVideo(sourceDirName); err != nil {
+		return nil, err
+	}
+
+	videoInfo, err := getVideoInfo("./upload/video/"+sourceDirName+"/upload.mp4", nil)
+	if err != nil {
+		return nil, errors.New("读取视频信息失败")
+	}
+
+	return clipChapters(getProbeChapters(videoInfo), parseClips(resource.Clips)), nil
+}
+
+// 获取ffprobe输出的章节
+func getProbeChapters(videoInfo global.VideoInfo) []dto.Chapter {
+	chapters := make([]dto.Chapter, 0, len(videoInfo.Chapters))
+	for i, c := range videoInfo.Chapters {
+		start, err := strconv.ParseFloat(c.StartTime, 64)
+		if err != nil {
+			continue
+		}
+
+		// 没有标题的章节使用序号
+		title := c.Tags.Title
+		if strings.TrimSpace(title) == "" {
+			title = "章节" + strconv.Itoa(i+1)
+		}
+		chapters = append(chapters, dto.Chapter{Start: start, Title: title})
+	}
+
+	return chapters
+}
+
+// 将源视频时间的章节转换为剪辑后的时间，被剪掉的章节移到下一个片段开头
+func clipChapters(chapters []dto.Chapter, ranges []dto.ClipRange) []dto.Chapter {
+	if len(ranges) == 0 {
+		return chapters
+	}
+
+	result := make([]dto.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		if start, ok := sourceToClipTime(c.Start, ranges); ok {
+			c.Start = start
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// 导入视频文件中的章节，资源已有章节时不覆盖
+func importTranscodingChapters(transcodingInfo *dto.TranscodingInfo) {
+	if len(transcodingInfo.Chapters) == 0 {
+		return
+	}
+
+	var count int64
+	global.Mysql.Model(&model.Chapter{}).Where("resource_id = ?", transcodingInfo.ResourceID).Count(&count)
+	if count > 0 {
+		return
+	}
+
+	var resource model.Resource
+	global.Mysql.Where("id = ?", transcodingInfo.ResourceID).First(&resource)
+	if resource.ID == 0 {
+		return
+	}
+
+	chapters := filterChapters(clipChapters(transcodingInfo.Chapters, transcodingInfo.Clips), transcodingInfo.Duration)
+	if len(chapters) > 0 {
+		saveChapters(resource, chapters)
+	}
+}
+
+// 剪辑后按新的时间轴调整章节
+func remapChapters(resource model.Resource, oldRanges, newRanges []dto.ClipRange, duration float64) {
+	var chapters []model.Chapter
+	global.Mysql.Where("resource_id = ?", resource.ID).Order("start").Find(&chapters)
+	if len(chapters) == 0 {
+		return
+	}
+
+	remapped := make([]dto.Chapter, 0, len(chapters))
+	for _, c := range chapters {
+		remapped = append(remapped, dto.Chapter{Start: clipToSourceTime(c.Start, oldRanges), Title: c.Title})
+	}
+	saveChapters(resource, filterChapters(clipChapters(remapped, newRanges), duration))
+}
+
+// 保存章节，覆盖原有章节
+func saveChapters(resource model.Resource, chapters []dto.Chapter) error {
+	if err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("resource_id = ?", resource.ID).Delete(&model.Chapter{}).Error; err != nil {
+			return err
+		}
+		if len(chapters) == 0 {
+			return nil
+		}
+
+		records := make([]model.Chapter, 0, len(chapters))
+		for _, c := range chapters {
+			records = append(records, model.Chapter{ResourceID: resource.ID, Start: c.Start, Title: c.Title})
+		}
+		return tx.Create(&records).Error
+	}); err != nil {
+		utils.ErrorLog("保存章节失败", "chapter", err.Error())
+		return errors.New("保存章节失败")
+	}
+
+	// 更新视频信息缓存
+	cache.DelVideoInfo(resource.Vid)
+
+	return nil
+}
+
+// 生成章节WebVTT，每个章节到下一个章节开始时结束
+func buildChapterVTT(chapters []model.Chapter, duration float64) string {
+	var builder strings.Builder
+	builder.WriteString("WEBVTT\n\n")
+	for i, c := range chapters {
+		end := duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		if end <= c.Start {
+			continue
+		}
+
+		builder.WriteString(strconv.Itoa(i+1) + "\n")
+		builder.WriteString(formatVTTTimestamp(c.Start) + " --> " + formatVTTTimestamp(end) + "\n")
+		builder.WriteString(sanitizeChapterTitle(c.Title) + "\n\n")
+	}
+
+	return builder.String()
+}
+
+// 填充资源的章节
+func fillResourceChapters(resources []vo.ResourceResp) {
+	if len(resources) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(resources))
+	for _, r := range resources {
+		ids = append(ids, r.ID)
+	}
+
+	var chapters []model.Chapter
+	global.Mysql.Where("resource_id in ?", ids).Order("start").Find(&chapters)
+	for i := range resources {
+		for _, c := range chapters {
+			if c.ResourceID == resources[i].ID {
+				resources[i].Chapters = append(resources[i].Chapters, vo.ChapterResp{Start: c.Start, Title: c.Title})
+			}
+		}
+		if len(resources[i].Chapters) > 0 {
+			resources[i].ChapterVTT = "/api/v1/video/getChapterFile?resourceId=" + utils.UintToString(resources[i].ID)
+		}
+	}
+}
+
+func chaptersToChapterResp(chapters []dto.Chapter) []vo.ChapterResp {
+	res := make([]vo.ChapterResp, 0, len(chapters))
+	for _, c := range chapters {
+		res = append(res, vo.ChapterResp{Start: c.Start, Title: c.Title})
+	}
+
+	return res
+}
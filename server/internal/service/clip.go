@@ -0,0 +1,272 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	CLIP_MAX_RANGES  = 20 // 最多保留的片段数量
+	CLIP_MIN_SECONDS = 1  // 每个片段的最短时长（秒）
+)
+
+// 剪辑资源，从上传的源文件重新生成切片
+func ClipResource(ctx *gin.Context, clipReq dto.ClipResourceReq) error {
+	var resource model.Resource
+	userId := ctx.GetUint("userId")
+	global.Mysql.Model(&model.Resource{}).Where("id = ? and uid = ?", clipReq.ID, userId).First(&resource)
+	if resource.ID == 0 {
+		return errors.New("资源不存在")
+	}
+
+	// 只能在提交审核前剪辑
+	video, err := FindVideoById(resource.Vid)
+	if err != nil || (video.Status != global.CREATED_VIDEO && video.Status != global.REVIEW_FAILED) {
+		return errors.New("当前状态无法剪辑")
+	}
+	if resource.Status == global.VIDEO_PROCESSING {
+		return errors.New("视频处理中，请稍后再试")
+	}
+
+	sourceDirName := getSourceDirName(resource.ID)
+	if sourceDirName == "" {
+		return errors.New("源视频文件不存在")
+	}
+
+	// 源视频时长在转码任务中校验，End为0时保留到任务执行时再替换
+	ranges := clipReq.Ranges
+	if len(ranges) == 0 {
+		ranges = []dto.ClipRange{{Start: clipReq.Start, End: clipReq.End}}
+	}
+	if _, err := verifyClipRanges(ranges, math.MaxFloat64); err != nil {
+		return err
+	}
+
+	// 通过状态条件更新，避免重复提交
+	result := global.Mysql.Model(&model.Resource{}).Where("id = ? and status <> ?", resource.ID, global.VIDEO_PROCESSING).
+		Update("status", global.VIDEO_PROCESSING)
+	if result.Error != nil || result.RowsAffected == 0 {
+		if result.Error != nil {
+			utils.ErrorLog("更新资源状态失败", "resource", result.Error.Error())
+		}
+		return errors.New("剪辑失败")
+	}
+
+	// 输出到新目录，剪辑片段及原有切片在转码完成后替换
+	data, _ := json.Marshal(ranges)
+	if err := addClipTranscodingTask(resource, sourceDirName, string(data)); err != nil {
+		completeTransCoding(resource.Vid, resource.ID, global.PROCESSING_FAIL)
+		return err
+	}
+
+	return nil
+}
+
+// 剪辑结果，在任务提交时写入资源
+type clipResult struct {
+	Ranges   []dto.ClipRange // 保留的片段，保留完整视频时为空
+	Clips    string
+	Duration float64
+}
+
+// 按源视频时长校验剪辑任务的片段
+func getClipResult(clips string, sourceDuration float64) (*clipResult, error) {
+	ranges, err := verifyClipRanges(parseClips(clips), sourceDuration)
+	if err != nil {
+		return nil, err
+	}
+	if len(ranges) == 0 {
+		return nil, errors.New("剪辑范围有误")
+	}
+
+	// 保留完整视频时清除剪辑
+	if len(ranges) == 1 && ranges[0].Start == 0 && ranges[0].End == sourceDuration {
+		return &clipResult{Duration: sourceDuration}, nil
+	}
+
+	data, _ := json.Marshal(ranges)
+	return &clipResult{Ranges: ranges, Clips: string(data), Duration: getClipDuration(ranges)}, nil
+}
+
+// 剪辑任务提交后调整章节及视频时长，resource为剪辑前的资源
+func applyClipResult(resource model.Resource, clip *clipResult) {
+	// 章节按剪辑后的时间轴调整
+	remapChapters(resource, parseClips(resource.Clips), clip.Ranges, clip.Duration)
+
+	// 字幕按剪辑后的时间轴重新切片
+	resource.Duration = clip.Duration
+	oldRanges := parseClips(resource.Clips)
+	regenerateSubtitles(resource, func(cue subtitleCue) (subtitleCue, bool) {
+		return remapSubtitleCue(cue, oldRanges, clip.Ranges, clip.Duration)
+	})
+
+	// 更新视频时长
+	var videoDuration float64
+	global.Mysql.Model(&model.Resource{}).Where("vid = ?", resource.Vid).Pluck("SUM(duration) as duration", &videoDuration)
+	global.Mysql.Model(&model.Video{}).Where("id = ?", resource.Vid).Update("duration", videoDuration)
+}
+
+// 校验并排序保留的片段，End为0时表示到视频结尾
+func verifyClipRanges(ranges []dto.ClipRange, duration float64) ([]dto.ClipRange, error) {
+	if len(ranges) > CLIP_MAX_RANGES {
+		return nil, errors.New("剪辑片段过多")
+	}
+
+	result := make([]dto.ClipRange, len(ranges))
+	for i, r := range ranges {
+		if r.End == 0 {
+			r.End = duration
+		}
+		if r.Start < 0 || r.End > duration || r.End-r.Start < CLIP_MIN_SECONDS {
+			return nil, errors.New("剪辑范围有误")
+		}
+		result[i] = r
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start < result[j].Start
+	})
+	for i := 1; i < len(result); i++ {
+		if result[i].Start < result[i-1].End {
+			return nil, errors.New("剪辑片段不能重叠")
+		}
+	}
+
+	return result, nil
+}
+
+// 创建剪辑的转码任务
+func addClipTranscodingTask(resource model.Resource, sourceDirName, clips string) error {
+	return createTranscodingTask(&model.TranscodingTask{
+		Vid:           resource.Vid,
+		ResourceID:    resource.ID,
+		DirName:       sourceDirName,
+		Clip:          true,
+		Clips:         clips,
+		OutputDirName: generateVideoFilename(),
+	})
+}
+
+// 解析资源的剪辑片段
+func parseClips(clips string) []dto.ClipRange {
+	if clips == "" {
+		return nil
+	}
+
+	var ranges []dto.ClipRange
+	if err := json.Unmarshal([]byte(clips), &ranges); err != nil {
+		utils.ErrorLog("解析剪辑片段失败", "transcoding", err.Error())
+		return nil
+	}
+
+	return ranges
+}
+
+// 剪辑后的总时长
+func getClipDuration(ranges []dto.ClipRange) float64 {
+	duration := 0.0
+	for _, r := range ranges {
+		duration += r.End - r.Start
+	}
+
+	return math.Round(duration*1000) / 1000
+}
+
+// 剪辑后的时间转换为源视频时间
+func clipToSourceTime(t float64, ranges []dto.ClipRange) float64 {
+	offset := 0.0
+	for _, r := range ranges {
+		if t < offset+r.End-r.Start {
+			return r.Start + t - offset
+		}
+		offset += r.End - r.Start
+	}
+	if len(ranges) > 0 {
+		return ranges[len(ranges)-1].End
+	}
+
+	return t
+}
+
+// 源视频时间转换为剪辑后的时间，位于被剪掉的部分时移到下一个片段开头
+func sourceToClipTime(t float64, ranges []dto.ClipRange) (float64, bool) {
+	offset := 0.0
+	for _, r := range ranges {
+		if t < r.End {
+			return offset + math.Max(t-r.Start, 0), true
+		}
+		offset += r.End - r.Start
+	}
+
+	return 0, false
+}
+
+// 将旧剪辑时间轴上的字幕转换到新剪辑的时间轴，完全位于被剪掉部分的字幕被丢弃
+func remapSubtitleCue(cue subtitleCue, oldRanges, newRanges []dto.ClipRange, duration float64) (subtitleCue, bool) {
+	start := clipToSourceTime(cue.Start, oldRanges)
+	end := clipToSourceTime(cue.End, oldRanges)
+	if len(newRanges) > 0 {
+		var ok bool
+		if start, ok = sourceToClipTime(start, newRanges); !ok {
+			return cue, false
+		}
+		if end, ok = sourceToClipTime(end, newRanges); !ok {
+			end = duration
+		}
+	}
+	if end <= start {
+		return cue, false
+	}
+
+	cue.Start, cue.End = start, end
+	return cue, true
+}
+
+// 生成剪辑滤镜，将input中保留的片段拼接为一路输出，返回滤镜及输出标签
+func clipFilters(input string, ranges []dto.ClipRange, audio bool) ([]string, string) {
+	trim, setpts, split, prefix := "trim", "setpts", "split", "cv"
+	if audio {
+		trim, setpts, split, prefix = "atrim", "asetpts", "asplit", "ca"
+	}
+	output := "[" + prefix + "]"
+
+	// 单个片段不需要拼接
+	if len(ranges) == 1 {
+		return []string{fmt.Sprintf("%s%s=start=%.3f:end=%.3f,%s=PTS-STARTPTS%s",
+			input, trim, ranges[0].Start, ranges[0].End, setpts, output)}, output
+	}
+
+	filters := make([]string, 0, len(ranges)+2)
+	splitFilter := input + split + "=" + fmt.Sprint(len(ranges))
+	concat := ""
+	for i, r := range ranges {
+		splitFilter += fmt.Sprintf("[%s%d]", prefix, i)
+		filters = append(filters, fmt.Sprintf("[%s%d]%s=start=%.3f:end=%.3f,%s=PTS-STARTPTS[%s%dt]",
+			prefix, i, trim, r.Start, r.End, setpts, prefix, i))
+		concat += fmt.Sprintf("[%s%dt]", prefix, i)
+	}
+
+	v, a := 1, 0
+	if audio {
+		v, a = 0, 1
+	}
+	concat += fmt.Sprintf("concat=n=%d:v=%d:a=%d%s", len(ranges), v, a, output)
+
+	return append(append([]string{splitFilter}, filters...), concat), output
+}
+
+// 剪辑片段的命令行参数，用于只有一路输出的滤镜
+func clipFilterArgs(input string, ranges []dto.ClipRange, audio bool, filter string) []string {
+	filters, output := clipFilters(input, ranges, audio)
+	return []string{"-filter_complex", strings.Join(append(filters, output+filter), ";")}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"interastral-peace.com/alnitak/internal/domain/dto"
+)
+
+func TestVerifyClipRanges(t *testing.T) {
+	tooMany := make([]dto.ClipRange, CLIP_MAX_RANGES+1)
+	for i := range tooMany {
+		tooMany[i] = dto.ClipRange{Start: float64(i * 2), End: float64(i*2 + 1)}
+	}
+
+	tests := []struct {
+		name     string
+		ranges   []dto.ClipRange
+		duration float64
+		want     []dto.ClipRange
+		wantErr  bool
+	}{
+		{
+			name:     "排序片段",
+			ranges:   []dto.ClipRange{{Start: 30, End: 40}, {Start: 0, End: 10}},
+			duration: 60,
+			want:     []dto.ClipRange{{Start: 0, End: 10}, {Start: 30, End: 40}},
+		},
+		{
+			name:     "结束为0时到视频结尾",
+			ranges:   []dto.ClipRange{{Start: 50, End: 0}},
+			duration: 60,
+			want:     []dto.ClipRange{{Start: 50, End: 60}},
+		},
+		{
+			name:     "片段相邻",
+			ranges:   []dto.ClipRange{{Start: 0, End: 10}, {Start: 10, End: 20}},
+			duration: 60,
+			want:     []dto.ClipRange{{Start: 0, End: 10}, {Start: 10, End: 20}},
+		},
+		{
+			name:     "片段重叠",
+			ranges:   []dto.ClipRange{{Start: 0, End: 10}, {Start: 5, End: 20}},
+			duration: 60,
+			wantErr:  true,
+		},
+		{
+			name:     "开始时间为负",
+			ranges:   []dto.ClipRange{{Start: -1, End: 10}},
+			duration: 60,
+			wantErr:  true,
+		},
+		{
+			name:     "超出视频时长",
+			ranges:   []dto.ClipRange{{Start: 0, End: 61}},
+			duration: 60,
+			wantErr:  true,
+		},
+		{
+			name:     "片段过短",
+			ranges:   []dto.ClipRange{{Start: 10, End: 10.5}},
+			duration: 60,
+			wantErr:  true,
+		},
+		{
+			name:     "片段过多",
+			ranges:   tooMany,
+			duration: 100,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyClipRanges(tt.ranges, tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyClipRanges() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("verifyClipRanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClipToSourceTime(t *testing.T) {
+	ranges := []dto.ClipRange{{Start: 10, End: 20}, {Start: 30, End: 45}}
+
+	tests := []struct {
+		name   string
+		t      float64
+		ranges []dto.ClipRange
+		want   float64
+	}{
+		{"第一个片段开头", 0, ranges, 10},
+		{"第一个片段中间", 5, ranges, 15},
+		{"第二个片段开头", 10, ranges, 30},
+		{"第二个片段中间", 12.5, ranges, 32.5},
+		{"超出剪辑时长", 30, ranges, 45},
+		{"没有剪辑", 12, nil, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clipToSourceTime(tt.t, tt.ranges); got != tt.want {
+				t.Errorf("clipToSourceTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 
 	"github.com/spf13/viper"
 	"interastral-peace.com/alnitak/internal/config"
@@ -66,8 +68,6 @@ func GetStorageConfig() vo.StorageConfigResp {
 		Region:   global.Config.Storage.Region,
 		Domain:   global.Config.Storage.Domain,
 		Private:  global.Config.Storage.Private,
-
-		UploadMp4File: global.Config.Storage.UploadMp4File,
 	}
 }
 
@@ -81,15 +81,14 @@ func SetStorageConfig(storageConfigReq dto.StorageConfigReq) error {
 	}
 
 	global.Config.Storage = config.Storage{
-		OssType:       storageConfigReq.Type,
-		KeyId:         storageConfigReq.KeyID,
-		Bucket:        storageConfigReq.Bucket,
-		Endpoint:      storageConfigReq.Endpoint,
-		AppId:         storageConfigReq.AppID,
-		Region:        storageConfigReq.Region,
-		Domain:        storageConfigReq.Domain,
-		Private:       storageConfigReq.Private,
-		UploadMp4File: storageConfigReq.UploadMp4File,
+		OssType:  storageConfigReq.Type,
+		KeyId:    storageConfigReq.KeyID,
+		Bucket:   storageConfigReq.Bucket,
+		Endpoint: storageConfigReq.Endpoint,
+		AppId:    storageConfigReq.AppID,
+		Region:   storageConfigReq.Region,
+		Domain:   storageConfigReq.Domain,
+		Private:  storageConfigReq.Private,
 	}
 
 	viper.Set("file.max_img_size", storageConfigReq.MaxImgSize)
@@ -102,7 +101,6 @@ func SetStorageConfig(storageConfigReq dto.StorageConfigReq) error {
 	viper.Set("storage.region", storageConfigReq.Region)
 	viper.Set("storage.domain", storageConfigReq.Domain)
 	viper.Set("storage.private", storageConfigReq.Private)
-	viper.Set("storage.upload_mp4_file", storageConfigReq.UploadMp4File)
 
 	if len(storageConfigReq.KeySecret) != 0 {
 		global.Config.Storage.KeySecret = storageConfigReq.KeySecret
@@ -144,10 +142,8 @@ func SetOtherConfig(otherConfigReq dto.OtherConfigReq) error {
 	global.Config.User = config.User{
 		Prefix: otherConfigReq.Prefix,
 	}
-	global.Config.Transcoding = config.Transcoding{
-		Generate1080p60: otherConfigReq.Generate1080p60,
-		UseGpu:          otherConfigReq.UseGpu,
-	}
+	global.Config.Transcoding.Generate1080p60 = otherConfigReq.Generate1080p60
+	global.Config.Transcoding.UseGpu = otherConfigReq.UseGpu
 
 	viper.Set("cors.allow_origin", otherConfigReq.AllowOrigin)
 	viper.Set("user.prefix", otherConfigReq.Prefix)
@@ -164,3 +160,187 @@ func SetOtherConfig(otherConfigReq dto.OtherConfigReq) error {
 
 	return nil
 }
+
+// 码率格式，例如 3000k
+var bitrateRegexp = regexp.MustCompile(`^[1-9][0-9]*[kM]$`)
+
+func GetTranscodingConfig() vo.TranscodingConfigResp {
+	ladder := make([]vo.TranscodingRungResp, 0, len(global.Config.Transcoding.Ladder))
+	for _, rung := range global.Config.Transcoding.Ladder {
+		ladder = append(ladder, vo.TranscodingRungResp{
+			Width:        rung.Width,
+			Height:       rung.Height,
+			Bitrate:      rung.Bitrate,
+			MaxFps:       rung.MaxFps,
+			Codec:        rung.Codec,
+			Crf:          rung.Crf,
+			Preset:       rung.Preset,
+			AudioBitrate: rung.AudioBitrate,
+		})
+	}
+
+	return vo.TranscodingConfigResp{
+		EmbeddedWorker: global.Config.Transcoding.EmbeddedWorker,
+		WorkerCount:    global.Config.Transcoding.WorkerCount,
+		MaxRetry:       global.Config.Transcoding.MaxRetry,
+		Packaging:      global.Config.Transcoding.Packaging,
+		Encrypt:        global.Config.Transcoding.Encrypt,
+		Loudnorm:       global.Config.Transcoding.Loudnorm,
+		TargetLufs:     global.Config.Transcoding.TargetLufs,
+		Ladder:         ladder,
+		Watermark: vo.WatermarkResp{
+			Image:    global.Config.Transcoding.Watermark.Image,
+			Text:     global.Config.Transcoding.Watermark.Text,
+			Position: global.Config.Transcoding.Watermark.Position,
+			Opacity:  global.Config.Transcoding.Watermark.Opacity,
+			Scale:    global.Config.Transcoding.Watermark.Scale,
+			FontFile: global.Config.Transcoding.Watermark.FontFile,
+		},
+	}
+}
+
+func SetTranscodingConfig(transcodingConfigReq dto.TranscodingConfigReq) error {
+	if transcodingConfigReq.WorkerCount <= 0 || transcodingConfigReq.MaxRetry < 0 {
+		return errors.New("转码任务配置有误")
+	}
+	if transcodingConfigReq.Packaging != global.PACKAGING_TS && transcodingConfigReq.Packaging != global.PACKAGING_CMAF {
+		return errors.New("封装格式有误")
+	}
+	if transcodingConfigReq.TargetLufs < -70 || transcodingConfigReq.TargetLufs > -5 {
+		return errors.New("目标响度有误")
+	}
+	if len(transcodingConfigReq.Ladder) == 0 {
+		return errors.New("至少需要一个转码档位")
+	}
+	if err := verifyWatermark(transcodingConfigReq.Watermark); err != nil {
+		return err
+	}
+
+	ladder := make([]config.TranscodingRung, 0, len(transcodingConfigReq.Ladder))
+	names := make([]string, 0, len(transcodingConfigReq.Ladder))
+	for _, rung := range transcodingConfigReq.Ladder {
+		if err := verifyTranscodingRung(rung); err != nil {
+			return err
+		}
+		transcodingRung := config.TranscodingRung{
+			Width:        rung.Width,
+			Height:       rung.Height,
+			Bitrate:      rung.Bitrate,
+			MaxFps:       rung.MaxFps,
+			Codec:        rung.Codec,
+			Crf:          rung.Crf,
+			Preset:       rung.Preset,
+			AudioBitrate: rung.AudioBitrate,
+		}
+		// 输出文件按分辨率、码率、帧率命名，不能重复，竖屏视频会交换宽高
+		name := fmt.Sprintf("%dx%d_%s_%s", utils.Max(rung.Width, rung.Height), utils.Min(rung.Width, rung.Height),
+			rung.Bitrate, getRungFpsName(transcodingRung))
+		if utils.IsStringInSlice(names, name) {
+			return errors.New("转码档位重复")
+		}
+		names = append(names, name)
+		ladder = append(ladder, transcodingRung)
+	}
+
+	oldTranscodingConfig := global.Config.Transcoding
+
+	global.Config.Transcoding.EmbeddedWorker = transcodingConfigReq.EmbeddedWorker
+	global.Config.Transcoding.WorkerCount = transcodingConfigReq.WorkerCount
+	global.Config.Transcoding.MaxRetry = transcodingConfigReq.MaxRetry
+	global.Config.Transcoding.Packaging = transcodingConfigReq.Packaging
+	global.Config.Transcoding.Encrypt = transcodingConfigReq.Encrypt
+	global.Config.Transcoding.Loudnorm = transcodingConfigReq.Loudnorm
+	global.Config.Transcoding.TargetLufs = transcodingConfigReq.TargetLufs
+	global.Config.Transcoding.Ladder = ladder
+	global.Config.Transcoding.Watermark = config.Watermark{
+		Image:    transcodingConfigReq.Watermark.Image,
+		Text:     transcodingConfigReq.Watermark.Text,
+		Position: transcodingConfigReq.Watermark.Position,
+		Opacity:  transcodingConfigReq.Watermark.Opacity,
+		Scale:    transcodingConfigReq.Watermark.Scale,
+		FontFile: transcodingConfigReq.Watermark.FontFile,
+	}
+
+	viper.Set("transcoding.embedded_worker", transcodingConfigReq.EmbeddedWorker)
+	viper.Set("transcoding.worker_count", transcodingConfigReq.WorkerCount)
+	viper.Set("transcoding.max_retry", transcodingConfigReq.MaxRetry)
+	viper.Set("transcoding.packaging", transcodingConfigReq.Packaging)
+	viper.Set("transcoding.encrypt", transcodingConfigReq.Encrypt)
+	viper.Set("transcoding.loudnorm", transcodingConfigReq.Loudnorm)
+	viper.Set("transcoding.target_lufs", transcodingConfigReq.TargetLufs)
+	viper.Set("transcoding.ladder", ladder)
+	viper.Set("transcoding.watermark.image", transcodingConfigReq.Watermark.Image)
+	viper.Set("transcoding.watermark.text", transcodingConfigReq.Watermark.Text)
+	viper.Set("transcoding.watermark.position", transcodingConfigReq.Watermark.Position)
+	viper.Set("transcoding.watermark.opacity", transcodingConfigReq.Watermark.Opacity)
+	viper.Set("transcoding.watermark.scale", transcodingConfigReq.Watermark.Scale)
+	viper.Set("transcoding.watermark.font_file", transcodingConfigReq.Watermark.FontFile)
+
+	if err := viper.WriteConfig(); err != nil {
+		global.Config.Transcoding = oldTranscodingConfig
+		utils.ErrorLog("写入转码配置失败", "config", err.Error())
+		return errors.New("更新失败")
+	}
+
+	// 独立的worker进程需要重启后生效
+	SetEmbeddedTranscodingWorker(transcodingConfigReq.EmbeddedWorker)
+	ResizeTranscodingWorkers(transcodingConfigReq.WorkerCount)
+
+	return nil
+}
+
+// 转码档位的CRF范围
+const (
+	TRANSCODING_MIN_CRF = 10
+	TRANSCODING_MAX_CRF = 51
+)
+
+// 校验转码档位
+func verifyTranscodingRung(rung dto.TranscodingRungReq) error {
+	// 编码器要求宽高为偶数
+	if rung.Width <= 0 || rung.Height <= 0 || rung.Width%2 != 0 || rung.Height%2 != 0 {
+		return errors.New("分辨率有误")
+	}
+	if !bitrateRegexp.MatchString(rung.Bitrate) || !bitrateRegexp.MatchString(rung.AudioBitrate) {
+		return errors.New("码率格式有误")
+	}
+	if rung.MaxFps <= 0 || rung.MaxFps > 120 {
+		return errors.New("帧率有误")
+	}
+	// CRF为0时为无损编码，码率会远超限制
+	if rung.Crf < TRANSCODING_MIN_CRF || rung.Crf > TRANSCODING_MAX_CRF {
+		return errors.New("CRF有误")
+	}
+	encoder, ok := videoEncoders[rung.Codec]
+	if !ok {
+		return errors.New("不支持的编码器")
+	}
+	// 预设为空时使用编码器的默认预设
+	if rung.Preset != "" && !utils.IsStringInSlice(encoder.Presets(), rung.Preset) {
+		return errors.New("编码预设有误")
+	}
+
+	return nil
+}
+
+// 校验水印配置
+func verifyWatermark(watermark dto.WatermarkReq) error {
+	positions := []string{global.WATERMARK_TOP_LEFT, global.WATERMARK_TOP_RIGHT, global.WATERMARK_BOTTOM_LEFT, global.WATERMARK_BOTTOM_RIGHT}
+	if !utils.IsStringInSlice(positions, watermark.Position) {
+		return errors.New("水印位置有误")
+	}
+	if watermark.Opacity <= 0 || watermark.Opacity > 1 {
+		return errors.New("水印不透明度有误")
+	}
+	if watermark.Scale <= 0 || watermark.Scale > 1 {
+		return errors.New("水印比例有误")
+	}
+	if watermark.Image != "" && !utils.IsFileExists(watermark.Image) {
+		return errors.New("水印图片不存在")
+	}
+	if watermark.FontFile != "" && !utils.IsFileExists(watermark.FontFile) {
+		return errors.New("水印字体不存在")
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	COVER_CANDIDATE_COUNT = 4   // 候选封面数量
+	COVER_SCENE_THRESHOLD = 0.3 // 场景切换阈值
+	COVER_MIN_LUMA        = 40  // 平均亮度低于该值视为黑帧
+)
+
+// 场景检测失败或数量不足时，按视频时长的固定比例截取
+var coverFallbackPercents = []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+
+// 生成候选封面，返回封面url
+func generateCoverCandidates(userId uint, videoPath string, duration float64) []string {
+	tempDir, err := os.MkdirTemp("", "cover")
+	if err != nil {
+		utils.ErrorLog("创建临时目录失败", "transcoding", err.Error())
+		return nil
+	}
+	defer os.RemoveAll(tempDir)
+
+	frames := extractSceneFrames(videoPath, tempDir)
+	if len(frames) < COVER_CANDIDATE_COUNT {
+		frames = append(frames, extractFallbackFrames(videoPath, tempDir, duration)...)
+	}
+
+	urls := make([]string, 0, COVER_CANDIDATE_COUNT)
+	for _, frame := range frames {
+		if len(urls) >= COVER_CANDIDATE_COUNT {
+			break
+		}
+		if isDarkImage(frame) {
+			continue
+		}
+
+		coverName := generateImgFilename(".jpg")
+		objectKey := "image/" + coverName
+		filePath := "./upload/image/" + coverName
+		if err := utils.CopyFile(frame, filePath); err != nil {
+			utils.ErrorLog("保存封面失败", "transcoding", err.Error())
+			continue
+		}
+		if global.Config.Storage.OssType != "local" {
+			// 上传到OSS
+			global.Storage.PutObjectFromFile(objectKey, filePath)
+		}
+
+		// 作为用户上传的图片，可直接用作视频封面，没有被选择的封面由定时任务清理
+		url := generateFileUrl(objectKey)
+		cache.SetUploadImage(url, userId)
+		cache.AddCoverCandidate(url)
+		urls = append(urls, url)
+	}
+
+	return urls
+}
+
+// 通过场景检测提取画面，只解码关键帧以减少耗时
+func extractSceneFrames(videoPath, outputDir string) []string {
+	filter := fmt.Sprintf("select='gt(scene,%g)',scale=1280:-2", COVER_SCENE_THRESHOLD)
+	command := []string{"-skip_frame", "nokey", "-i", videoPath, "-vf", filter, "-vsync", "vfr",
+		"-frames:v", fmt.Sprint(COVER_CANDIDATE_COUNT * 3), "-q:v", "2", "-y", outputDir + "/scene_%02d.jpg",
+	}
+	if _, err := utils.RunCmd(exec.Command("ffmpeg", command...)); err != nil {
+		utils.ErrorLog("场景检测失败", "transcoding", err.Error())
+	}
+
+	frames, _ := filepath.Glob(outputDir + "/scene_*.jpg")
+	sort.Strings(frames)
+	return frames
+}
+
+// 按固定比例截取画面
+func extractFallbackFrames(videoPath, outputDir string, duration float64) []string {
+	frames := make([]string, 0, len(coverFallbackPercents))
+	for i, percent := range coverFallbackPercents {
+		outputFile := fmt.Sprintf("%s/fixed_%02d.jpg", outputDir, i)
+		command := []string{"-ss", fmt.Sprintf("%.3f", duration*percent), "-i", videoPath,
+			"-vf", "scale=1280:-2", "-vframes", "1", "-q:v", "2", "-y", outputFile,
+		}
+		if _, err := utils.RunCmd(exec.Command("ffmpeg", command...)); err != nil {
+			utils.ErrorLog("截取封面失败", "transcoding", err.Error())
+			continue
+		}
+		frames = append(frames, outputFile)
+	}
+
+	return frames
+}
+
+// 是否为接近全黑的画面
+func isDarkImage(file string) bool {
+	f, err := os.Open(file)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return true
+	}
+
+	// 间隔采样计算平均亮度
+	bounds := img.Bounds()
+	step := utils.Max(1, utils.Min(bounds.Dx(), bounds.Dy())/64)
+	var total, count int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			total += int(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			count++
+		}
+	}
+
+	return count == 0 || total/count < COVER_MIN_LUMA
+}
+
+// 清理没有被使用的候选封面，超过上传图片的有效期后不能再被选择
+func CleanCoverCandidates() {
+	for _, url := range cache.GetCoverCandidatesBefore(time.Now().Add(-cache.UPLOAD_IMAGE_EXPRIRATION_TIME)) {
+		if !isImageUsed(url) {
+			objectKey := strings.TrimPrefix(url, "/api/")
+			if global.Config.Storage.OssType != "local" {
+				if err := global.Storage.DeleteObject(objectKey); err != nil {
+					utils.ErrorLog("删除OSS文件失败", "oss", err.Error())
+				}
+			}
+			os.Remove("./upload/" + objectKey)
+		}
+		cache.DelCoverCandidate(url)
+	}
+}
+
+// 图片是否被使用，候选封面同样可以用于其他需要上传图片的地方
+func isImageUsed(url string) bool {
+	for _, query := range []*gorm.DB{
+		global.Mysql.Model(&model.Video{}).Where("cover = ?", url),
+		global.Mysql.Model(&model.Article{}).Where("cover = ?", url),
+		// 文章正文中插入的图片
+		global.Mysql.Model(&model.Article{}).Where("content like ?", "%"+url+"%"),
+		global.Mysql.Model(&model.Collection{}).Where("cover = ?", url),
+		global.Mysql.Model(&model.Carousel{}).Where("img = ?", url),
+		global.Mysql.Model(&model.User{}).Where("avatar = ? or space_cover = ?", url, url),
+	} {
+		var count int64
+		if query.Count(&count); count > 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VAAPI默认使用的设备
+const VAAPI_DEVICE = "/dev/dri/renderD128"
+
+// 视频编码器，负责生成对应的ffmpeg参数
+type videoEncoder interface {
+	// 编码格式 (h264、hevc、av1)
+	Family() string
+	// 输入前的全局参数，如硬件设备
+	GlobalArgs() []string
+	// 缩放后追加的滤镜
+	Filter() string
+	// 编码参数
+	Args(target TranscodingTarget) []string
+	// 支持的编码预设，为空时不支持设置预设
+	Presets() []string
+}
+
+// 软件编码器 (libx264、libx265、libsvtav1)
+type softwareEncoder struct {
+	name          string
+	family        string
+	defaultPreset string
+	presets       []string
+}
+
+// x264和x265的编码预设
+var x26xPresets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow", "placebo"}
+
+// SVT-AV1的编码预设，数值越小质量越高
+var svtAv1Presets = []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"}
+
+// NVENC的编码预设
+var nvencPresets = []string{"p1", "p2", "p3", "p4", "p5", "p6", "p7"}
+
+// NVIDIA硬件编码器
+type nvencEncoder struct {
+	name   string
+	family string
+}
+
+// VAAPI硬件编码器 (Intel/AMD)
+type vaapiEncoder struct {
+	name   string
+	family string
+}
+
+// 支持的编码器
+var videoEncoders = map[string]videoEncoder{
+	"libx264":    &softwareEncoder{name: "libx264", family: "h264", presets: x26xPresets},
+	"libx265":    &softwareEncoder{name: "libx265", family: "hevc", presets: x26xPresets},
+	"libsvtav1":  &softwareEncoder{name: "libsvtav1", family: "av1", defaultPreset: "8", presets: svtAv1Presets},
+	"h264_nvenc": &nvencEncoder{name: "h264_nvenc", family: "h264"},
+	"hevc_nvenc": &nvencEncoder{name: "hevc_nvenc", family: "hevc"},
+	"av1_nvenc":  &nvencEncoder{name: "av1_nvenc", family: "av1"},
+	"h264_vaapi": &vaapiEncoder{name: "h264_vaapi", family: "h264"},
+	"hevc_vaapi": &vaapiEncoder{name: "hevc_vaapi", family: "hevc"},
+	"av1_vaapi":  &vaapiEncoder{name: "av1_vaapi", family: "av1"},
+}
+
+// 开启GPU加速时软件编码器对应的硬件编码器
+var gpuEncoders = map[string]string{
+	"libx264":   "h264_nvenc",
+	"libx265":   "hevc_nvenc",
+	"libsvtav1": "av1_nvenc",
+}
+
+// 获取编码器
+func getVideoEncoder(codec string, useGpu bool) (videoEncoder, bool) {
+	if gpuCodec, ok := gpuEncoders[codec]; ok && useGpu {
+		codec = gpuCodec
+	}
+
+	encoder, ok := videoEncoders[codec]
+	return encoder, ok
+}
+
+// 获取支持的编码器名称
+func GetVideoEncoderNames() []string {
+	names := make([]string, 0, len(videoEncoders))
+	for name := range videoEncoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// HEVC和AV1只能使用fMP4封装
+func requireFmp4(encoder videoEncoder) bool {
+	return encoder.Family() != "h264"
+}
+
+func (e *softwareEncoder) Family() string {
+	return e.family
+}
+
+func (e *softwareEncoder) GlobalArgs() []string {
+	return nil
+}
+
+func (e *softwareEncoder) Filter() string {
+	// 统一输出8bit，10bit的H.264浏览器无法播放
+	return "format=yuv420p"
+}
+
+func (e *softwareEncoder) Args(target TranscodingTarget) []string {
+	// 限制最大码率的CRF模式
+	args := []string{"-c:v", e.name, "-crf", strconv.Itoa(target.Crf),
+		"-maxrate", target.BitrateRate, "-bufsize", doubleBitrate(target.BitrateRate),
+	}
+
+	preset := target.Preset
+	if preset == "" {
+		preset = e.defaultPreset
+	}
+	if preset != "" {
+		args = append(args, "-preset", preset)
+	}
+
+	if e.family == "hevc" {
+		args = append(args, "-tag:v", "hvc1", "-x265-params", "log-level=error")
+	}
+
+	return args
+}
+
+func (e *softwareEncoder) Presets() []string {
+	return e.presets
+}
+
+func (e *nvencEncoder) Family() string {
+	return e.family
+}
+
+func (e *nvencEncoder) GlobalArgs() []string {
+	return nil
+}
+
+func (e *nvencEncoder) Filter() string {
+	return "format=yuv420p"
+}
+
+func (e *nvencEncoder) Args(target TranscodingTarget) []string {
+	// NVENC的预设为p1-p7
+	preset := "p3"
+	if strings.HasPrefix(target.Preset, "p") {
+		preset = target.Preset
+	}
+
+	args := []string{"-c:v", e.name, "-preset", preset, "-rc", "vbr", "-cq", strconv.Itoa(target.Crf),
+		"-b:v", target.BitrateRate, "-maxrate", target.BitrateRate, "-bufsize", doubleBitrate(target.BitrateRate),
+	}
+	if e.family == "hevc" {
+		args = append(args, "-tag:v", "hvc1")
+	}
+
+	return args
+}
+
+func (e *nvencEncoder) Presets() []string {
+	return nvencPresets
+}
+
+func (e *vaapiEncoder) Family() string {
+	return e.family
+}
+
+func (e *vaapiEncoder) GlobalArgs() []string {
+	return []string{"-vaapi_device", VAAPI_DEVICE}
+}
+
+func (e *vaapiEncoder) Filter() string {
+	return "format=nv12,hwupload"
+}
+
+func (e *vaapiEncoder) Args(target TranscodingTarget) []string {
+	args := []string{"-c:v", e.name, "-b:v", target.BitrateRate, "-maxrate", target.BitrateRate,
+		"-bufsize", doubleBitrate(target.BitrateRate),
+	}
+	if e.family == "hevc" {
+		args = append(args, "-tag:v", "hvc1")
+	}
+
+	return args
+}
+
+// 码率翻倍，用于缓冲区大小 (如 3000k -> 6000k)
+func doubleBitrate(bitrate string) string {
+	if len(bitrate) < 2 {
+		return bitrate
+	}
+
+	value, err := strconv.Atoi(bitrate[:len(bitrate)-1])
+	if err != nil {
+		return bitrate
+	}
+
+	return strconv.Itoa(value*2) + bitrate[len(bitrate)-1:]
+}
+
+func (e *vaapiEncoder) Presets() []string {
+	return nil
+}
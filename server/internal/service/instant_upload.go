@@ -0,0 +1,198 @@
+package service
+
+import (
+	"path"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 秒传，存在相同hash且已校验的文件时直接使用，不需要重新上传
+// 其他用户的文件只有在已审核通过的视频中使用过才能秒传，避免通过hash获取未公开的视频
+func instantUploadVideo(userId uint, hash, fileName string) bool {
+	if hash == "" || !utils.IsVideoType(path.Ext(fileName)) || utf8.RuneCountInString(fileName) > 100 {
+		return false
+	}
+
+	var sourceFile model.VideoFile
+	global.Mysql.Where("hash = ? and verified = ?", hash, true).
+		Where("dir_name in (?)", global.Mysql.Model(&model.TranscodingTask{}).Select("dir_name").
+			Where("resource_id in (?)", liveResources().Select("id").Where("status = ?", global.AUDIT_APPROVED))).
+		Order("id desc").Limit(1).Find(&sourceFile)
+	if sourceFile.ID == 0 {
+		return false
+	}
+
+	if err := fetchSourceVideo(sourceFile.DirName); err != nil {
+		return false
+	}
+
+	// 记录当前用户对文件的引用，之后按正常流程创建视频
+	fileInfo := model.VideoFile{
+		Uid:          userId,
+		OriginalName: fileName,
+		DirName:      sourceFile.DirName,
+		Hash:         sourceFile.Hash,
+		ChunksCount:  sourceFile.ChunksCount,
+		Size:         sourceFile.Size,
+		Verified:     true,
+	}
+	if err := global.Mysql.Create(&fileInfo).Error; err != nil {
+		utils.ErrorLog("保存视频文件信息失败", "upload", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// 查找使用相同源文件且转码完成的资源，有水印或剪辑的资源不能复用
+func findReusableResource(dirName string) model.Resource {
+	var resource model.Resource
+	liveResources().
+		Where("watermark = ? and (clips = '' or clips is null) and status <> ?", false, global.VIDEO_PROCESSING).
+		Where("id in (?)", global.Mysql.Model(&model.TranscodingTask{}).Select("resource_id").
+			Where("dir_name = ? and status = ?", dirName, global.TRANSCODING_DONE)).
+		Where("id in (?)", global.Mysql.Model(&model.VideoIndexFile{}).Select("resource_id")).
+		Order("id desc").Limit(1).Find(&resource)
+
+	return resource
+}
+
+// 复用已有资源的切片、密钥和缩略图，并记录一个已完成的任务用于查找源文件
+func reuseTranscodedFiles(resource, source model.Resource, dirName string, transcodingInfo *dto.TranscodingInfo) error {
+	if err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		var indexFiles []model.VideoIndexFile
+		if err := tx.Where("resource_id = ?", source.ID).Find(&indexFiles).Error; err != nil {
+			return err
+		}
+		if len(indexFiles) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for i := range indexFiles {
+			indexFiles[i].Model = gorm.Model{}
+			indexFiles[i].ResourceID = resource.ID
+		}
+		if err := tx.Create(&indexFiles).Error; err != nil {
+			return err
+		}
+
+		var videoKey model.VideoKey
+		tx.Where("resource_id = ? and dir_name = ?", source.ID, indexFiles[0].DirName).Limit(1).Find(&videoKey)
+		if videoKey.ID != 0 {
+			if err := saveVideoKey(tx, &model.VideoKey{ResourceID: resource.ID, DirName: videoKey.DirName, Secret: videoKey.Secret, IV: videoKey.IV}); err != nil {
+				return err
+			}
+		}
+
+		var storyboard model.Storyboard
+		tx.Where("resource_id = ?", source.ID).Limit(1).Find(&storyboard)
+		if storyboard.ID != 0 {
+			if err := tx.Create(&model.Storyboard{ResourceID: resource.ID, DirName: storyboard.DirName, Content: storyboard.Content}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 切片目录与源目录不同时记录输出目录，重新转码及剪辑时从源目录读取
+		task := model.TranscodingTask{
+			Vid:        resource.Vid,
+			ResourceID: resource.ID,
+			DirName:    dirName,
+			Status:     global.TRANSCODING_DONE,
+		}
+		if indexFiles[0].DirName != dirName {
+			task.OutputDirName = indexFiles[0].DirName
+		}
+		return tx.Create(&task).Error
+	}); err != nil {
+		utils.ErrorLog("复用转码文件失败", "upload", err.Error())
+		return err
+	}
+
+	transcodingInfo.ResourceID = resource.ID
+	importTranscodingChapters(transcodingInfo)
+
+	return nil
+}
+
+// 目录是否已被转码任务或切片使用
+func isVideoDirReferenced(dirName string) bool {
+	var count int64
+	global.Mysql.Model(&model.TranscodingTask{}).Where("(dir_name = ? or output_dir_name = ?)", dirName, dirName).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	global.Mysql.Model(&model.VideoIndexFile{}).Where("dir_name = ?", dirName).Count(&count)
+	return count > 0
+}
+
+// 释放被删除资源引用的文件，目录没有其他资源及用户引用时删除
+func releaseVideoFiles(resources []model.Resource) {
+	for _, resource := range resources {
+		deleteResourceSubtitles(resource.ID)
+		for _, dirName := range getResourceDirNames(resource.ID) {
+			// 用户没有其他资源使用该目录时，删除用户对文件的引用
+			if !isVideoDirUsed(dirName, liveResources().Select("id").Where("uid = ?", resource.Uid)) {
+				global.Mysql.Where("uid = ? and dir_name = ?", resource.Uid, dirName).Delete(&model.VideoFile{})
+			}
+
+			var owners int64
+			global.Mysql.Model(&model.VideoFile{}).Where("dir_name = ?", dirName).Count(&owners)
+			if owners > 0 || isVideoDirUsed(dirName, liveResources().Select("id")) {
+				continue
+			}
+
+			removeTranscodingFiles(dirName, false)
+		}
+	}
+}
+
+// 获取资源使用过的所有目录
+func getResourceDirNames(resourceId uint) []string {
+	var tasks []model.TranscodingTask
+	global.Mysql.Where("resource_id = ?", resourceId).Find(&tasks)
+	var indexFiles []model.VideoIndexFile
+	global.Mysql.Where("resource_id = ?", resourceId).Find(&indexFiles)
+
+	dirNames := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(dirName string) {
+		if dirName != "" && !seen[dirName] {
+			seen[dirName] = true
+			dirNames = append(dirNames, dirName)
+		}
+	}
+	for _, task := range tasks {
+		add(task.DirName)
+		add(task.OutputDirName)
+	}
+	for _, indexFile := range indexFiles {
+		add(indexFile.DirName)
+	}
+
+	return dirNames
+}
+
+// 目录是否被指定资源的转码任务或切片使用
+func isVideoDirUsed(dirName string, resourceIds *gorm.DB) bool {
+	var count int64
+	global.Mysql.Model(&model.TranscodingTask{}).Where("(dir_name = ? or output_dir_name = ?)", dirName, dirName).
+		Where("resource_id in (?)", resourceIds).Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	global.Mysql.Model(&model.VideoIndexFile{}).Where("dir_name = ?", dirName).
+		Where("resource_id in (?)", resourceIds).Count(&count)
+	return count > 0
+}
+
+// 未删除且所属视频未删除的资源
+func liveResources() *gorm.DB {
+	return global.Mysql.Model(&model.Resource{}).
+		Where("vid in (?)", global.Mysql.Model(&model.Video{}).Select("id"))
+}
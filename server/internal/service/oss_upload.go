@@ -0,0 +1,216 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/pkg/oss"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	OSS_UPLOAD_PART_SIZE       = 10 * utils.MB // 默认分片大小
+	OSS_UPLOAD_MAX_PARTS       = 10000         // OSS允许的最大分片数量
+	OSS_UPLOAD_PRESIGN_MAX     = 100           // 每次最多签名的分片数量
+	OSS_UPLOAD_PRESIGN_EXPIRES = time.Hour     // 分片上传URL有效期
+)
+
+// 直传信息，文件已上传完成时Completed为true
+type OssUploadInfo struct {
+	PartSize  int64
+	PartCount int
+	Completed bool
+}
+
+// 初始化OSS直传，相同文件重新初始化时继续之前的上传
+// 需要在存储桶的跨域设置中允许PUT请求并暴露ETag响应头
+func OssUploadInit(ctx *gin.Context, initReq dto.OssUploadInitReq) (OssUploadInfo, error) {
+	userId := ctx.GetUint("userId")
+	if global.Config.Storage.OssType == "local" {
+		return OssUploadInfo{}, errors.New("未开启OSS直传")
+	}
+	if initReq.Hash == "" || len(initReq.Hash) > 64 || utf8.RuneCountInString(initReq.Name) > 100 {
+		return OssUploadInfo{}, errors.New("文件信息有误")
+	}
+	if !utils.IsVideoType(path.Ext(initReq.Name)) {
+		return OssUploadInfo{}, errors.New("文件类型错误")
+	}
+	if initReq.Size <= 0 || !utils.FileSize(initReq.Size, 1, global.Config.File.MaxVideoSize) {
+		return OssUploadInfo{}, errors.New("文件大小超出限制")
+	}
+
+	info := OssUploadInfo{PartSize: getOssUploadPartSize(initReq.Size)}
+	info.PartCount = int((initReq.Size + info.PartSize - 1) / info.PartSize)
+
+	var fileInfo model.VideoFile
+	global.Mysql.Where("uid = ? and hash = ?", userId, initReq.Hash).Limit(1).Find(&fileInfo)
+	if fileInfo.Verified || (fileInfo.ID != 0 && fileInfo.UploadID == "" && isOssUploadCompleted(fileInfo.DirName)) {
+		info.Completed = true
+		return info, nil
+	}
+	if fileInfo.UploadID != "" && fileInfo.Size == initReq.Size {
+		return info, nil
+	}
+
+	if fileInfo.ID == 0 {
+		fileInfo = model.VideoFile{Uid: userId, Hash: initReq.Hash, DirName: generateVideoFilename(), OriginalName: initReq.Name}
+	} else if fileInfo.UploadID != "" {
+		// 文件大小变化时放弃之前的上传
+		if err := global.Storage.AbortMultipartUpload(getOssUploadKey(fileInfo.DirName), fileInfo.UploadID); err != nil {
+			utils.ErrorLog("取消OSS分片上传失败", "oss", err.Error())
+		}
+	}
+
+	uploadId, err := global.Storage.InitiateMultipartUpload(getOssUploadKey(fileInfo.DirName))
+	if err != nil {
+		utils.ErrorLog("初始化OSS分片上传失败", "oss", err.Error())
+		return OssUploadInfo{}, errors.New("初始化上传失败")
+	}
+
+	fileInfo.UploadID = uploadId
+	fileInfo.Size = initReq.Size
+	fileInfo.ChunksCount = info.PartCount
+	if err := global.Mysql.Save(&fileInfo).Error; err != nil {
+		utils.ErrorLog("保存视频文件信息失败", "upload", err.Error())
+		return OssUploadInfo{}, errors.New("初始化上传失败")
+	}
+
+	return info, nil
+}
+
+// 获取分片的预签名上传URL
+func OssUploadPresign(ctx *gin.Context, presignReq dto.OssUploadPresignReq) (map[int]string, error) {
+	fileInfo, err := findOssUpload(ctx, presignReq.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(presignReq.PartNumbers) == 0 || len(presignReq.PartNumbers) > OSS_UPLOAD_PRESIGN_MAX {
+		return nil, errors.New("分片数量有误")
+	}
+
+	urls := make(map[int]string, len(presignReq.PartNumbers))
+	objectKey := getOssUploadKey(fileInfo.DirName)
+	for _, partNumber := range presignReq.PartNumbers {
+		if partNumber < 1 || partNumber > fileInfo.ChunksCount {
+			return nil, errors.New("分片信息有误")
+		}
+
+		url, err := global.Storage.PresignUploadPart(objectKey, fileInfo.UploadID, partNumber, OSS_UPLOAD_PRESIGN_EXPIRES)
+		if err != nil {
+			utils.ErrorLog("生成分片上传URL失败", "oss", err.Error())
+			return nil, errors.New("获取上传地址失败")
+		}
+		urls[partNumber] = url
+	}
+
+	return urls, nil
+}
+
+// 完成OSS直传，文件在转码下载时校验hash
+func OssUploadComplete(ctx *gin.Context, completeReq dto.OssUploadCompleteReq) error {
+	fileInfo, err := findOssUpload(ctx, completeReq.Hash)
+	if err != nil {
+		return err
+	}
+	if len(completeReq.Parts) != fileInfo.ChunksCount {
+		return errors.New("视频分片缺失")
+	}
+
+	parts := make([]oss.UploadPart, 0, len(completeReq.Parts))
+	for _, part := range completeReq.Parts {
+		if part.PartNumber < 1 || part.PartNumber > fileInfo.ChunksCount || part.ETag == "" {
+			return errors.New("分片信息有误")
+		}
+		parts = append(parts, oss.UploadPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	objectKey := getOssUploadKey(fileInfo.DirName)
+	if err := global.Storage.CompleteMultipartUpload(objectKey, fileInfo.UploadID, parts); err != nil {
+		utils.ErrorLog("完成OSS分片上传失败", "oss", err.Error())
+		return errors.New("合并分片失败")
+	}
+
+	// 合并后的文件大小与申请上传时不一致时删除文件
+	size, err := global.Storage.GetObjectSize(objectKey)
+	if err != nil || size != fileInfo.Size {
+		if err != nil {
+			utils.ErrorLog("获取OSS文件大小失败", "oss", err.Error())
+		} else {
+			utils.ErrorLog("OSS文件大小不一致", "oss", fmt.Sprintf("%d %d", fileInfo.Size, size))
+		}
+		removeOssUpload(fileInfo)
+		return errors.New("视频文件校验失败")
+	}
+	global.Mysql.Model(&model.VideoFile{}).Where("id = ?", fileInfo.ID).Update("upload_id", "")
+
+	return nil
+}
+
+// 取消OSS直传
+func OssUploadAbort(ctx *gin.Context, videoFileReq dto.VideoFileReq) error {
+	fileInfo, err := findOssUpload(ctx, videoFileReq.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := global.Storage.AbortMultipartUpload(getOssUploadKey(fileInfo.DirName), fileInfo.UploadID); err != nil {
+		utils.ErrorLog("取消OSS分片上传失败", "oss", err.Error())
+		return errors.New("取消上传失败")
+	}
+	removeOssUpload(fileInfo)
+
+	return nil
+}
+
+// 查找当前用户进行中的直传
+func findOssUpload(ctx *gin.Context, hash string) (model.VideoFile, error) {
+	var fileInfo model.VideoFile
+	userId := ctx.GetUint("userId")
+	global.Mysql.Where("uid = ? and hash = ?", userId, hash).Limit(1).Find(&fileInfo)
+	if fileInfo.ID == 0 || fileInfo.UploadID == "" {
+		return fileInfo, errors.New("上传不存在")
+	}
+
+	return fileInfo, nil
+}
+
+// 删除直传的文件及记录
+func removeOssUpload(fileInfo model.VideoFile) {
+	if err := global.Storage.DeleteObject(getOssUploadKey(fileInfo.DirName)); err != nil {
+		utils.ErrorLog("删除OSS文件失败", "oss", err.Error())
+	}
+	global.Mysql.Where("id = ?", fileInfo.ID).Delete(&model.VideoFile{})
+	os.RemoveAll("./upload/video/" + fileInfo.DirName)
+}
+
+// 分片大小，超过最大分片数量时增大分片
+func getOssUploadPartSize(size int64) int64 {
+	partSize := int64(OSS_UPLOAD_PART_SIZE)
+	if minSize := (size + OSS_UPLOAD_MAX_PARTS - 1) / OSS_UPLOAD_MAX_PARTS; minSize > partSize {
+		partSize = minSize
+	}
+
+	return partSize
+}
+
+// 直传的文件是否已合并完成
+func isOssUploadCompleted(dirName string) bool {
+	exists, err := global.Storage.IsExists(getOssUploadKey(dirName))
+	return err == nil && exists
+}
+
+func getOssUploadKey(dirName string) string {
+	return "video/" + dirName + "/upload.mp4"
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"interastral-peace.com/alnitak/utils"
+)
+
+func TestGetOssUploadPartSize(t *testing.T) {
+	tests := []struct {
+		name string
+		size int64
+		want int64
+	}{
+		{"小文件", 1 * utils.MB, OSS_UPLOAD_PART_SIZE},
+		{"刚好达到最大分片数量", OSS_UPLOAD_PART_SIZE * OSS_UPLOAD_MAX_PARTS, OSS_UPLOAD_PART_SIZE},
+		{"超过最大分片数量", OSS_UPLOAD_PART_SIZE*OSS_UPLOAD_MAX_PARTS + 1, OSS_UPLOAD_PART_SIZE + 1},
+		{"大文件向上取整", 200 * 1024 * utils.MB, 21474837},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getOssUploadPartSize(tt.size)
+			if got != tt.want {
+				t.Errorf("getOssUploadPartSize(%d) = %d, want %d", tt.size, got, tt.want)
+			}
+			if (tt.size+got-1)/got > OSS_UPLOAD_MAX_PARTS {
+				t.Errorf("getOssUploadPartSize(%d) exceeds max parts", tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,473 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 主播放列表中字幕的分组
+const SUBTITLE_GROUP_ID = "subs"
+
+// 主播放列表中音频的分组，CMAF的视频流不含音频时使用
+const AUDIO_GROUP_ID = "audio"
+
+// 切片文件类型
+var sliceContentTypes = map[string]string{
+	".ts":  "video/mp2t",
+	".m4s": "video/iso.segment",
+	".mp4": "video/mp4",
+	".vtt": "text/vtt",
+	".jpg": "image/jpeg",
+}
+
+// 获取切片文件的Content-Type，mp4只允许fMP4的初始化分片
+// 源文件upload.mp4及密钥文件enc.key、enc.keyinfo不允许获取
+func GetVideoSliceContentType(file string) (string, bool) {
+	ext := filepath.Ext(file)
+	if ext == ".mp4" && !strings.HasSuffix(file, "_init.mp4") {
+		return "", false
+	}
+
+	contentType, ok := sliceContentTypes[ext]
+	return contentType, ok
+}
+
+// 是否为需要上传的转码输出文件，源文件在加入转码队列前单独上传
+func isTranscodingOutput(file string) bool {
+	_, ok := GetVideoSliceContentType(file)
+	return ok
+}
+
+// 填充变体流信息（码率、分辨率、编码、帧率），用于生成主播放列表
+func fillVariantInfo(indexFile *model.VideoIndexFile, outputDir string) {
+	segments := parseSegments(indexFile.Content)
+	if len(segments) == 0 {
+		return
+	}
+
+	// 峰值码率按单个切片的大小和时长计算
+	for _, seg := range segments {
+		stat, err := os.Stat(outputDir + seg.Name)
+		if err != nil || seg.Duration <= 0 {
+			continue
+		}
+		bandwidth := int(float64(stat.Size()*8) / seg.Duration)
+		if bandwidth > indexFile.Bandwidth {
+			indexFile.Bandwidth = bandwidth
+		}
+	}
+
+	// fMP4的编码信息在初始化分片中
+	probeFile := segments[0].Name
+	if initFile := parseInitSegment(indexFile.Content); initFile != "" {
+		probeFile = initFile
+	}
+	info, err := getVideoInfo(outputDir+probeFile, nil)
+	if err != nil {
+		utils.ErrorLog("读取切片信息失败", "transcoding", err.Error())
+		return
+	}
+
+	codecs := make([]string, 0, 2)
+	for _, stream := range info.Stream {
+		switch stream.CodecType {
+		case "video":
+			indexFile.Width = stream.Width
+			indexFile.Height = stream.Height
+			indexFile.FrameRate = parseFrameRate(stream.AvgFrameRate)
+		case "audio":
+		default:
+			continue
+		}
+		if codec := getCodecString(stream); codec != "" {
+			codecs = append(codecs, codec)
+		}
+	}
+	indexFile.Codecs = strings.Join(codecs, ",")
+}
+
+type segment struct {
+	Name     string
+	Duration float64
+}
+
+// 解析m3u8中的切片
+func parseSegments(content string) []segment {
+	segments := make([]segment, 0)
+	duration := 0.0
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#EXTINF:") {
+			value := strings.SplitN(line[len("#EXTINF:"):], ",", 2)[0]
+			duration, _ = strconv.ParseFloat(value, 64)
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			segments = append(segments, segment{Name: line, Duration: duration})
+			duration = 0
+		}
+	}
+
+	return segments
+}
+
+// 解析fMP4初始化分片 (#EXT-X-MAP:URI="xxx_init.mp4")
+func parseInitSegment(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#EXT-X-MAP:") {
+			if _, uri, found := strings.Cut(line, "URI=\""); found {
+				return strings.SplitN(uri, "\"", 2)[0]
+			}
+		}
+	}
+
+	return ""
+}
+
+// 解析帧率 (如 "30000/1001")
+func parseFrameRate(frameRate string) float64 {
+	parts := strings.Split(frameRate, "/")
+	if len(parts) != 2 {
+		return 0
+	}
+	numerator, _ := strconv.ParseFloat(parts[0], 64)
+	denominator, _ := strconv.ParseFloat(parts[1], 64)
+	if denominator == 0 {
+		return 0
+	}
+
+	return numerator / denominator
+}
+
+// 生成RFC 6381格式的编码字符串
+func getCodecString(stream global.Streams) string {
+	switch stream.CodecName {
+	case "h264":
+		profile, constraint := "64", "00"
+		switch stream.Profile {
+		case "Baseline":
+			profile = "42"
+		case "Constrained Baseline":
+			profile, constraint = "42", "E0"
+		case "Main":
+			profile, constraint = "4D", "40"
+		}
+		return fmt.Sprintf("avc1.%s%s%02X", profile, constraint, stream.Level)
+	case "hevc":
+		// Main为1，Main 10为2，均为Main Tier
+		if stream.Profile == "Main 10" {
+			return fmt.Sprintf("hvc1.2.4.L%d.B0", stream.Level)
+		}
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", stream.Level)
+	case "av1":
+		profile := 0
+		switch stream.Profile {
+		case "High":
+			profile = 1
+		case "Professional":
+			profile = 2
+		}
+		level := stream.Level
+		if level <= 0 {
+			level = 8 // 4.0
+		}
+		return fmt.Sprintf("av01.%d.%02dM.%02d", profile, level, getBitDepth(stream))
+	case "aac":
+		if stream.Profile == "HE-AAC" {
+			return "mp4a.40.5"
+		}
+		return "mp4a.40.2"
+	case "mp3":
+		return "mp4a.40.34"
+	}
+
+	return ""
+}
+
+// 从质量名称 (如 "1920x1080_3000k_30") 推断变体信息，兼容没有变体信息的旧数据
+func fillVariantInfoFromQuality(indexFile *model.VideoIndexFile) {
+	parts := strings.Split(indexFile.Quality, "_")
+	if len(parts) != 3 {
+		return
+	}
+	if resolution := strings.Split(parts[0], "x"); len(resolution) == 2 {
+		indexFile.Width = utils.StringToInt(resolution[0])
+		indexFile.Height = utils.StringToInt(resolution[1])
+	}
+	// 码率为平均码率，预留一定余量作为峰值
+	indexFile.Bandwidth = utils.StringToInt(strings.TrimSuffix(parts[1], "k")) * 1000 * 6 / 5
+	indexFile.FrameRate = float64(utils.StringToInt(parts[2]))
+}
+
+// 分离纯音频流，视频流不含音频时（CMAF）返回作为共用音频轨道的纯音频流
+func splitAudioRendition(indexFiles []model.VideoIndexFile) ([]model.VideoIndexFile, *model.VideoIndexFile) {
+	videoFiles := make([]model.VideoIndexFile, 0, len(indexFiles))
+	var audioFile *model.VideoIndexFile
+	for i := range indexFiles {
+		if indexFiles[i].Quality == AUDIO_QUALITY {
+			audioFile = &indexFiles[i]
+		} else {
+			videoFiles = append(videoFiles, indexFiles[i])
+		}
+	}
+
+	// 旧数据及TS切片的视频流包含音频，纯音频流只用于后台播放
+	if audioFile == nil || parseInitSegment(audioFile.Content) == "" || len(videoFiles) == 0 ||
+		videoFiles[0].Codecs == "" || strings.Contains(videoFiles[0].Codecs, "mp4a") {
+		return videoFiles, nil
+	}
+
+	return videoFiles, audioFile
+}
+
+// 生成主播放列表，audioFile不为空时视频流通过音频分组引用纯音频流
+func generateMasterPlaylist(indexFiles []model.VideoIndexFile, audioFile *model.VideoIndexFile, subtitles []model.Subtitle,
+	variantUrl func(quality string) string, subtitleUrl func(id uint) string) string {
+	for i := range indexFiles {
+		if indexFiles[i].Bandwidth == 0 {
+			fillVariantInfoFromQuality(&indexFiles[i])
+		}
+	}
+	sort.SliceStable(indexFiles, func(i, j int) bool {
+		return indexFiles[i].Bandwidth < indexFiles[j].Bandwidth
+	})
+
+	var builder strings.Builder
+	builder.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	if audioFile != nil {
+		builder.WriteString(fmt.Sprintf("#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s\",DEFAULT=YES,AUTOSELECT=YES,URI=\"%s\"\n",
+			AUDIO_GROUP_ID, AUDIO_QUALITY, variantUrl(AUDIO_QUALITY)))
+	}
+	for _, subtitle := range subtitles {
+		builder.WriteString(fmt.Sprintf("#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\",LANGUAGE=\"%s\",DEFAULT=NO,AUTOSELECT=YES,URI=\"%s\"\n",
+			SUBTITLE_GROUP_ID, strings.ReplaceAll(subtitle.Name, "\"", "'"), subtitle.Lang, subtitleUrl(subtitle.ID)))
+	}
+	for _, file := range indexFiles {
+		// 码率及编码需要包含音频轨道
+		bandwidth := file.Bandwidth
+		codecs := file.Codecs
+		if audioFile != nil {
+			bandwidth += audioFile.Bandwidth
+			if codecs != "" && audioFile.Codecs != "" {
+				codecs += "," + audioFile.Codecs
+			}
+		}
+
+		builder.WriteString("#EXT-X-STREAM-INF:BANDWIDTH=" + strconv.Itoa(bandwidth))
+		if file.Width > 0 && file.Height > 0 {
+			builder.WriteString(fmt.Sprintf(",RESOLUTION=%dx%d", file.Width, file.Height))
+		}
+		if codecs != "" {
+			builder.WriteString(",CODECS=\"" + codecs + "\"")
+		}
+		if file.FrameRate > 0 {
+			builder.WriteString(",FRAME-RATE=" + strconv.FormatFloat(math.Round(file.FrameRate*1000)/1000, 'f', 3, 64))
+		}
+		if audioFile != nil {
+			builder.WriteString(",AUDIO=\"" + AUDIO_GROUP_ID + "\"")
+		}
+		if len(subtitles) > 0 {
+			builder.WriteString(",SUBTITLES=\"" + SUBTITLE_GROUP_ID + "\"")
+		}
+		builder.WriteString("\n" + variantUrl(file.Quality) + "\n")
+	}
+
+	return builder.String()
+}
+
+// 重写播放列表中的切片地址
+func rewriteVideoIndexFile(file model.VideoIndexFile) string {
+	return rewritePlaylist(file.Content, file.DirName, file.ResourceID)
+}
+
+func rewritePlaylist(content, dirName string, resourceId uint) string {
+	res := ""
+	key := uuid.New().String()
+	cache.SetVideoSlice(key, dirName)
+	initFile := parseInitSegment(content)
+	for _, line := range strings.Split(content, "\n") {
+		//根据关键词覆盖当前行
+		if _, ok := sliceContentTypes[filepath.Ext(line)]; ok && !strings.HasPrefix(line, "#") {
+			res += "/api/v1/video/slice/" + line + "?key=" + key + "\n"
+		} else if initFile != "" && strings.HasPrefix(line, "#EXT-X-MAP:") {
+			res += replaceTagUri(line, "/api/v1/video/slice/"+initFile+"?key="+key) + "\n"
+		} else if strings.HasPrefix(line, "#EXT-X-KEY:") {
+			res += replaceTagUri(line, "/api/v1/video/key?resourceId="+utils.UintToString(resourceId)+"&key="+key) + "\n"
+		} else {
+			res += line + "\n"
+		}
+	}
+
+	return res
+}
+
+// 获取主播放列表
+func GetVideoMasterFile(ctx *gin.Context, resourceId uint) (string, error) {
+	if !IsResourceExist(resourceId) {
+		return "", errors.New("资源不存在")
+	}
+
+	return getVideoMasterFile(resourceId, "/api/v1/video/getVideoFile", "/api/v1/video/getSubtitleFile")
+}
+
+// 获取主播放列表（后台管理）
+func GetVideoMasterFileManage(ctx *gin.Context, resourceId uint) (string, error) {
+	return getVideoMasterFile(resourceId, "/api/v1/video/getVideoFileManage", "/api/v1/video/getSubtitleFileManage")
+}
+
+func getVideoMasterFile(resourceId uint, variantPath, subtitlePath string) (string, error) {
+	// 纯音频流没有画面，不作为变体流，通过quality=audio单独获取
+	var indexFiles []model.VideoIndexFile
+	global.Mysql.Where("resource_id = ?", resourceId).Find(&indexFiles)
+	videoFiles, audioFile := splitAudioRendition(indexFiles)
+	if len(videoFiles) == 0 {
+		return "", errors.New("资源不存在")
+	}
+
+	var subtitles []model.Subtitle
+	global.Mysql.Where("resource_id = ?", resourceId).Order("id").Find(&subtitles)
+
+	resource := utils.UintToString(resourceId)
+	return generateMasterPlaylist(videoFiles, audioFile, subtitles, func(quality string) string {
+		return variantPath + "?resourceId=" + resource + "&quality=" + quality
+	}, func(id uint) string {
+		return subtitlePath + "?subtitleId=" + utils.UintToString(id)
+	}), nil
+}
+
+// 获取DASH描述文件
+func GetVideoMpdFile(ctx *gin.Context, resourceId uint) (string, error) {
+	if !IsResourceExist(resourceId) {
+		return "", errors.New("资源不存在")
+	}
+
+	return getVideoMpdFile(resourceId)
+}
+
+// 获取DASH描述文件（后台管理）
+func GetVideoMpdFileManage(ctx *gin.Context, resourceId uint) (string, error) {
+	return getVideoMpdFile(resourceId)
+}
+
+func getVideoMpdFile(resourceId uint) (string, error) {
+	var indexFiles []model.VideoIndexFile
+	global.Mysql.Where("resource_id = ?", resourceId).Find(&indexFiles)
+	if len(indexFiles) == 0 {
+		return "", errors.New("资源不存在")
+	}
+
+	// 只有fMP4切片可以用于DASH
+	videoFiles, audioFile := splitAudioRendition(indexFiles)
+	cmafFiles := make([]model.VideoIndexFile, 0, len(videoFiles))
+	for _, file := range videoFiles {
+		if parseInitSegment(file.Content) != "" {
+			cmafFiles = append(cmafFiles, file)
+		}
+	}
+	if len(cmafFiles) == 0 {
+		return "", errors.New("该资源不支持DASH播放")
+	}
+	if strings.Contains(cmafFiles[0].Content, "#EXT-X-KEY:") {
+		return "", errors.New("加密资源不支持DASH播放")
+	}
+
+	key := uuid.New().String()
+	cache.SetVideoSlice(key, cmafFiles[0].DirName)
+	return generateMpd(cmafFiles, audioFile, func(file string) string {
+		return "/api/v1/video/slice/" + file + "?key=" + key
+	}), nil
+}
+
+// 生成DASH描述文件，与HLS共用同一套fMP4切片，audioFile不为空时作为单独的音频自适应集
+func generateMpd(indexFiles []model.VideoIndexFile, audioFile *model.VideoIndexFile, sliceUrl func(file string) string) string {
+	for i := range indexFiles {
+		if indexFiles[i].Bandwidth == 0 {
+			fillVariantInfoFromQuality(&indexFiles[i])
+		}
+	}
+	sort.SliceStable(indexFiles, func(i, j int) bool {
+		return indexFiles[i].Bandwidth < indexFiles[j].Bandwidth
+	})
+
+	var videoSet, audioSet strings.Builder
+	duration := 0.0
+	for _, file := range indexFiles {
+		duration = math.Max(duration, writeMpdRepresentation(&videoSet, file, sliceUrl))
+	}
+	if audioFile != nil {
+		duration = math.Max(duration, writeMpdRepresentation(&audioSet, *audioFile, sliceUrl))
+	}
+
+	var builder strings.Builder
+	builder.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	builder.WriteString(fmt.Sprintf(`<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:full:2011" `+
+		`type="static" mediaPresentationDuration="PT%.3fS" minBufferTime="PT%dS">`+"\n", duration, HLS_SEGMENT_TIME))
+	builder.WriteString("  <Period id=\"0\" start=\"PT0S\">\n")
+	builder.WriteString("    <AdaptationSet id=\"0\" mimeType=\"video/mp4\" segmentAlignment=\"true\" startWithSAP=\"1\">\n")
+	builder.WriteString(videoSet.String())
+	builder.WriteString("    </AdaptationSet>\n")
+	if audioFile != nil {
+		builder.WriteString("    <AdaptationSet id=\"1\" mimeType=\"audio/mp4\" segmentAlignment=\"true\" startWithSAP=\"1\">\n")
+		builder.WriteString(audioSet.String())
+		builder.WriteString("    </AdaptationSet>\n")
+	}
+	builder.WriteString("  </Period>\n</MPD>\n")
+
+	return builder.String()
+}
+
+// 写入DASH的Representation，返回切片总时长
+func writeMpdRepresentation(builder *strings.Builder, file model.VideoIndexFile, sliceUrl func(file string) string) float64 {
+	segments := parseSegments(file.Content)
+	duration := 0.0
+	for _, seg := range segments {
+		duration += seg.Duration
+	}
+
+	builder.WriteString(fmt.Sprintf(`      <Representation id="%s" bandwidth="%d"`, html.EscapeString(file.Quality), file.Bandwidth))
+	if file.Width > 0 && file.Height > 0 {
+		builder.WriteString(fmt.Sprintf(` width="%d" height="%d"`, file.Width, file.Height))
+	}
+	if file.FrameRate > 0 {
+		builder.WriteString(` frameRate="` + formatDashFrameRate(file.FrameRate) + `"`)
+	}
+	if file.Codecs != "" {
+		builder.WriteString(` codecs="` + file.Codecs + `"`)
+	}
+	builder.WriteString(">\n        <SegmentList timescale=\"1000\">\n")
+	builder.WriteString(`          <Initialization sourceURL="` + html.EscapeString(sliceUrl(parseInitSegment(file.Content))) + "\"/>\n")
+	builder.WriteString("          <SegmentTimeline>\n")
+	for _, seg := range segments {
+		builder.WriteString(fmt.Sprintf("            <S d=\"%d\"/>\n", int(math.Round(seg.Duration*1000))))
+	}
+	builder.WriteString("          </SegmentTimeline>\n")
+	for _, seg := range segments {
+		builder.WriteString(`          <SegmentURL media="` + html.EscapeString(sliceUrl(seg.Name)) + "\"/>\n")
+	}
+	builder.WriteString("        </SegmentList>\n      </Representation>\n")
+
+	return duration
+}
+
+// DASH的帧率只能为整数或分数
+func formatDashFrameRate(frameRate float64) string {
+	if math.Abs(frameRate-math.Round(frameRate)) < 0.01 {
+		return strconv.Itoa(int(math.Round(frameRate)))
+	}
+
+	// NTSC帧率，如 29.97 = 30000/1001
+	return strconv.Itoa(int(math.Round(frameRate*1.001))*1000) + "/1001"
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+)
+
+func TestParseSegments(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []segment
+	}{
+		{
+			name:    "空文件",
+			content: "",
+			want:    []segment{},
+		},
+		{
+			name: "TS切片",
+			content: "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:6\n" +
+				"#EXTINF:6.000000,\n720p_0000.ts\n#EXTINF:3.500000,\n720p_0001.ts\n#EXT-X-ENDLIST\n",
+			want: []segment{{Name: "720p_0000.ts", Duration: 6}, {Name: "720p_0001.ts", Duration: 3.5}},
+		},
+		{
+			name: "CRLF换行及标题",
+			content: "#EXTM3U\r\n#EXT-X-MAP:URI=\"720p_init.mp4\"\r\n" +
+				"#EXTINF:4.004,title\r\n720p_0000.m4s\r\n",
+			want: []segment{{Name: "720p_0000.m4s", Duration: 4.004}},
+		},
+		{
+			name:    "缺少时长",
+			content: "#EXTM3U\n720p_0000.ts\n#EXTINF:2,\n720p_0001.ts\n",
+			want:    []segment{{Name: "720p_0000.ts", Duration: 0}, {Name: "720p_0001.ts", Duration: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSegments(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSegments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCodecString(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream global.Streams
+		want   string
+	}{
+		{"H.264 High", global.Streams{CodecName: "h264", Profile: "High", Level: 40}, "avc1.640028"},
+		{"H.264 Main", global.Streams{CodecName: "h264", Profile: "Main", Level: 31}, "avc1.4D401F"},
+		{"H.264 Baseline", global.Streams{CodecName: "h264", Profile: "Baseline", Level: 30}, "avc1.42001E"},
+		{"H.264 Constrained Baseline", global.Streams{CodecName: "h264", Profile: "Constrained Baseline", Level: 30}, "avc1.42E01E"},
+		{"HEVC Main", global.Streams{CodecName: "hevc", Profile: "Main", Level: 120}, "hvc1.1.6.L120.B0"},
+		{"HEVC Main 10", global.Streams{CodecName: "hevc", Profile: "Main 10", Level: 153}, "hvc1.2.4.L153.B0"},
+		{"AV1 Main 10bit", global.Streams{CodecName: "av1", Profile: "Main", Level: 9, PixFmt: "yuv420p10le"}, "av01.0.09M.10"},
+		{"AV1 缺少level", global.Streams{CodecName: "av1", Profile: "Main"}, "av01.0.08M.08"},
+		{"AAC LC", global.Streams{CodecName: "aac", Profile: "LC"}, "mp4a.40.2"},
+		{"HE-AAC", global.Streams{CodecName: "aac", Profile: "HE-AAC"}, "mp4a.40.5"},
+		{"MP3", global.Streams{CodecName: "mp3"}, "mp4a.40.34"},
+		{"不支持的编码", global.Streams{CodecName: "vp9"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getCodecString(tt.stream); got != tt.want {
+				t.Errorf("getCodecString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDashFrameRate(t *testing.T) {
+	tests := []struct {
+		frameRate float64
+		want      string
+	}{
+		{24, "24"},
+		{30, "30"},
+		{60.001, "60"},
+		{23.976, "24000/1001"},
+		{29.97, "30000/1001"},
+		{59.94, "60000/1001"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDashFrameRate(tt.frameRate); got != tt.want {
+			t.Errorf("formatDashFrameRate(%v) = %q, want %q", tt.frameRate, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateMpd(t *testing.T) {
+	video := func(quality string, bandwidth int) model.VideoIndexFile {
+		return model.VideoIndexFile{
+			Quality:   quality,
+			Bandwidth: bandwidth,
+			Width:     1280,
+			Height:    720,
+			FrameRate: 29.97,
+			Codecs:    "avc1.64001F",
+			Content: "#EXTM3U\n#EXT-X-MAP:URI=\"" + quality + "_init.mp4\"\n" +
+				"#EXTINF:4.000,\n" + quality + "_0000.m4s\n#EXTINF:2.500,\n" + quality + "_0001.m4s\n#EXT-X-ENDLIST\n",
+		}
+	}
+	audio := model.VideoIndexFile{
+		Quality:   "audio",
+		Bandwidth: 128000,
+		Codecs:    "mp4a.40.2",
+		Content:   "#EXTM3U\n#EXT-X-MAP:URI=\"audio_init.mp4\"\n#EXTINF:6.600,\naudio_0000.m4s\n#EXT-X-ENDLIST\n",
+	}
+	sliceUrl := func(file string) string {
+		return "/slice/" + file + "?key=a&b"
+	}
+
+	tests := []struct {
+		name       string
+		indexFiles []model.VideoIndexFile
+		audioFile  *model.VideoIndexFile
+		contains   []string
+		excludes   []string
+	}{
+		{
+			name:       "仅视频",
+			indexFiles: []model.VideoIndexFile{video("720p", 3000000)},
+			contains: []string{
+				`mediaPresentationDuration="PT6.500S"`,
+				`<Representation id="720p" bandwidth="3000000" width="1280" height="720" frameRate="30000/1001" codecs="avc1.64001F">`,
+				`<Initialization sourceURL="/slice/720p_init.mp4?key=a&amp;b"/>`,
+				`<S d="4000"/>`,
+				`<S d="2500"/>`,
+				`<SegmentURL media="/slice/720p_0001.m4s?key=a&amp;b"/>`,
+			},
+			excludes: []string{`mimeType="audio/mp4"`},
+		},
+		{
+			name:       "单独的音频",
+			indexFiles: []model.VideoIndexFile{video("1080p", 6000000), video("480p", 1000000)},
+			audioFile:  &audio,
+			contains: []string{
+				`mediaPresentationDuration="PT6.600S"`,
+				`<AdaptationSet id="1" mimeType="audio/mp4"`,
+				`<Representation id="audio" bandwidth="128000" codecs="mp4a.40.2">`,
+				`<SegmentURL media="/slice/audio_0000.m4s?key=a&amp;b"/>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := generateMpd(tt.indexFiles, tt.audioFile, sliceUrl)
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("generateMpd() missing %q\n%s", s, got)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("generateMpd() should not contain %q\n%s", s, got)
+				}
+			}
+		})
+	}
+
+	// 视频按码率升序排列
+	got := generateMpd([]model.VideoIndexFile{video("1080p", 6000000), video("480p", 1000000)}, nil, sliceUrl)
+	if strings.Index(got, `id="480p"`) > strings.Index(got, `id="1080p"`) {
+		t.Errorf("generateMpd() representations not sorted by bandwidth\n%s", got)
+	}
+}
@@ -51,6 +51,12 @@ func DeleteResource(ctx *gin.Context, id uint) error {
 		return errors.New("删除资源失败")
 	}
 
+	// 取消进行中的转码
+	CancelResourceTranscoding(id)
+
+	// 释放不再被引用的视频文件
+	go releaseVideoFiles([]model.Resource{resource})
+
 	// 更新视频信息缓存
 	cache.DelVideoInfo(resource.Vid)
 	VideoWriteCache(resource.Vid)
@@ -61,6 +67,8 @@ func DeleteResource(ctx *gin.Context, id uint) error {
 // 获取视频资源
 func GetVideoResourceByStatus(videoId uint, status int) (resources []vo.ResourceResp) {
 	global.Mysql.Model(&model.Resource{}).Where("vid = ? and status = ?", videoId, status).Scan(&resources)
+	fillResourceStoryboard(resources)
+	fillResourceChapters(resources)
 
 	return
 }
@@ -68,6 +76,8 @@ func GetVideoResourceByStatus(videoId uint, status int) (resources []vo.Resource
 // 获取视频资源
 func GetReviewResourceList(videoId uint) (resources []vo.ResourceResp) {
 	global.Mysql.Model(&model.Resource{}).Where("vid = ?", videoId).Scan(&resources)
+	fillResourceSubtitles(resources)
+	fillResourceChapters(resources)
 
 	return
 }
@@ -79,7 +89,8 @@ func GetResourceQuality(ctx *gin.Context, id uint) ([]string, error) {
 	}
 
 	var quality []string
-	if err := global.Mysql.Model(&model.VideoIndexFile{}).Where("resource_id = ?", id).
+	// 纯音频流不作为可选分辨率
+	if err := global.Mysql.Model(&model.VideoIndexFile{}).Where("resource_id = ? and quality <> ?", id, AUDIO_QUALITY).
 		Pluck("quality", &quality).Error; err != nil {
 		utils.ErrorLog("分辨率信息获取失败", "resource", err.Error())
 		return nil, errors.New("获取失败")
@@ -91,7 +102,8 @@ func GetResourceQuality(ctx *gin.Context, id uint) ([]string, error) {
 // 获取视频资源支持的分辨率信息(后台管理)
 func GetResourceQualityManage(ctx *gin.Context, id uint) ([]string, error) {
 	var quality []string
-	if err := global.Mysql.Model(&model.VideoIndexFile{}).Where("resource_id = ?", id).
+	// 纯音频流不作为可选分辨率
+	if err := global.Mysql.Model(&model.VideoIndexFile{}).Where("resource_id = ? and quality <> ?", id, AUDIO_QUALITY).
 		Pluck("quality", &quality).Error; err != nil {
 		utils.ErrorLog("分辨率信息获取失败", "resource", err.Error())
 		return nil, errors.New("获取失败")
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 被替换的切片保留时间，不小于切片key的有效期，避免正在播放的视频中断
+const RETRANSCODE_CLEANUP_DELAY = time.Hour * time.Duration(24)
+
+// 重新转码，返回加入队列的资源数量
+func RetranscodeResources(retranscodeReq dto.RetranscodeReq) (int, error) {
+	// 只处理已有转码结果且没有进行中任务的资源
+	query := global.Mysql.Model(&model.Resource{}).
+		Where("id in (?)", global.Mysql.Model(&model.VideoIndexFile{}).Select("resource_id")).
+		Where("id not in (?)", global.Mysql.Model(&model.TranscodingTask{}).Select("resource_id").
+			Where("status in ?", []int{global.TRANSCODING_UPLOADING, global.TRANSCODING_QUEUED, global.TRANSCODING_RUNNING}))
+	switch {
+	case retranscodeReq.All:
+	case len(retranscodeReq.ResourceIds) > 0:
+		query = query.Where("id in ?", retranscodeReq.ResourceIds)
+	case retranscodeReq.PartitionId != 0:
+		partitionIds := global.Mysql.Model(&model.Partition{}).Select("id").
+			Where("id = ? or parent_id = ?", retranscodeReq.PartitionId, retranscodeReq.PartitionId)
+		query = query.Where("vid in (?)", global.Mysql.Model(&model.Video{}).Select("id").Where("partition_id in (?)", partitionIds))
+	default:
+		return 0, errors.New("请选择需要重新转码的资源")
+	}
+
+	var resources []model.Resource
+	if err := query.Find(&resources).Error; err != nil {
+		utils.ErrorLog("查询重新转码资源失败", "transcoding", err.Error())
+		return 0, errors.New("查询资源失败")
+	}
+
+	count := 0
+	for _, resource := range resources {
+		sourceDirName := getSourceDirName(resource.ID)
+		if sourceDirName == "" {
+			utils.ErrorLog("找不到资源的源文件目录", "transcoding", utils.UintToString(resource.ID))
+			continue
+		}
+		// 之前的资源源文件可能只在本地，由上传协程上传后再转码
+		// 输出到新目录，原有切片在转码完成前继续提供播放
+		if err := createTranscodingTask(&model.TranscodingTask{
+			Vid:           resource.Vid,
+			ResourceID:    resource.ID,
+			DirName:       sourceDirName,
+			Retranscode:   true,
+			OutputDirName: generateVideoFilename(),
+		}); err != nil {
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// 获取资源的源文件目录
+func getSourceDirName(resourceId uint) string {
+	var task model.TranscodingTask
+	global.Mysql.Where("resource_id = ?", resourceId).Order("id desc").Limit(1).Find(&task)
+	if task.DirName != "" {
+		return task.DirName
+	}
+
+	// 转码队列之前的资源，切片与源文件在同一目录
+	var indexFile model.VideoIndexFile
+	global.Mysql.Where("resource_id = ?", resourceId).Limit(1).Find(&indexFile)
+	return indexFile.DirName
+}
+
+// 源文件不在本地时从OSS下载
+func fetchSourceVideo(dirName string) error {
+	filePath := "./upload/video/" + dirName + "/upload.mp4"
+	if utils.IsFileExists(filePath) {
+		return nil
+	}
+	if global.Config.Storage.OssType == "local" {
+		return errors.New("源视频文件不存在")
+	}
+
+	if err := os.MkdirAll("./upload/video/"+dirName, os.ModePerm); err != nil {
+		return err
+	}
+	if err := global.Storage.GetObjectToFile("video/"+dirName+"/upload.mp4", filePath); err != nil {
+		utils.ErrorLog("从OSS下载源视频失败", "oss", err.Error())
+		os.Remove(filePath)
+		return errors.New("源视频文件不存在")
+	}
+	if err := verifySourceVideo(dirName, filePath); err != nil {
+		os.Remove(filePath)
+		return err
+	}
+
+	return nil
+}
+
+// 校验从OSS下载的源文件，OSS直传的文件在第一次下载时校验hash
+func verifySourceVideo(dirName, filePath string) error {
+	var fileInfo model.VideoFile
+	global.Mysql.Where("dir_name = ? and verified = ? and upload_id = ''", dirName, false).Limit(1).Find(&fileInfo)
+	if fileInfo.ID == 0 {
+		return nil
+	}
+
+	fileMd5, err := utils.FileMD5(filePath)
+	if err != nil || !strings.EqualFold(fileMd5, fileInfo.Hash) {
+		utils.ErrorLog("视频文件校验失败", "upload", fileInfo.Hash+" "+fileMd5)
+		return errors.New("视频文件校验失败")
+	}
+	global.Mysql.Model(&model.VideoFile{}).Where("dir_name = ? and hash = ?", dirName, fileInfo.Hash).Update("verified", true)
+
+	return nil
+}
+
+// 获取读取源文件的地址，本地不存在时使用OSS的签名URL，不需要下载整个文件
+func getSourceVideoInput(dirName string) string {
+	filePath := "./upload/video/" + dirName + "/upload.mp4"
+	if global.Config.Storage.OssType == "local" || utils.IsFileExists(filePath) {
+		return filePath
+	}
+
+	return global.Storage.GetObjectUrl("video/" + dirName + "/upload.mp4")
+}
+
+// 使用OSS时将源文件上传到OSS，独立的转码worker、重新转码、剪辑及秒传都从OSS获取源文件
+func uploadSourceVideo(dirName string) error {
+	if global.Config.Storage.OssType == "local" {
+		return nil
+	}
+
+	objectKey := "video/" + dirName + "/upload.mp4"
+	if exists, err := global.Storage.IsExists(objectKey); err == nil && exists {
+		return nil
+	}
+	if err := global.Storage.PutObjectFromFile(objectKey, "./upload/"+objectKey); err != nil {
+		utils.ErrorLog("源视频上传OSS失败", "oss", err.Error())
+		return errors.New("上传源视频失败")
+	}
+
+	return nil
+}
+
+// 获取资源当前使用的切片目录
+func getIndexDirName(resourceId uint) string {
+	var indexFile model.VideoIndexFile
+	global.Mysql.Where("resource_id = ?", resourceId).Limit(1).Find(&indexFile)
+	return indexFile.DirName
+}
+
+// 清理重新转码后被替换的切片
+func CleanReplacedVideoFiles() {
+	var tasks []model.TranscodingTask
+	global.Mysql.Where("replaced_dir_name <> '' and updated_at < ?", time.Now().Add(-RETRANSCODE_CLEANUP_DELAY)).Find(&tasks)
+	for _, task := range tasks {
+		// 目录仍被使用时只清除标记
+		var count int64
+		global.Mysql.Model(&model.VideoIndexFile{}).Where("dir_name = ?", task.ReplacedDirName).Count(&count)
+		if count == 0 {
+			// 缩略图生成失败时仍指向旧目录，删除记录避免引用不存在的图片
+			global.Mysql.Where("resource_id = ? and dir_name = ?", task.ResourceID, task.ReplacedDirName).Delete(&model.Storyboard{})
+			removeTranscodingFiles(task.ReplacedDirName, task.ReplacedDirName == task.DirName)
+		}
+		// 旧目录的播放key已过期，不再需要对应的密钥
+		removeVideoKey(task.ResourceID, task.ReplacedDirName)
+
+		global.Mysql.Model(&model.TranscodingTask{}).Where("id = ?", task.ID).Update("replaced_dir_name", "")
+	}
+}
+
+// 删除转码生成的文件，源目录保留上传的视频文件
+func removeTranscodingFiles(dirName string, keepSource bool) {
+	// OSS中的文件按目录前缀删除，本地目录可能已被清理或位于其他节点
+	if global.Config.Storage.OssType != "local" {
+		keys, err := global.Storage.ListObjects("video/" + dirName + "/")
+		if err != nil {
+			utils.ErrorLog("获取OSS文件列表失败", "oss", err.Error())
+		}
+		for _, key := range keys {
+			if keepSource && path.Base(key) == "upload.mp4" {
+				continue
+			}
+			if err := global.Storage.DeleteObject(key); err != nil {
+				utils.ErrorLog("删除OSS文件失败", "oss", err.Error())
+			}
+		}
+	}
+
+	dir := "./upload/video/" + dirName + "/"
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return
+		}
+		utils.ErrorLog("读取视频文件夹失败", "transcoding", err.Error())
+		return
+	}
+
+	for _, f := range files {
+		if f.IsDir() || (keepSource && f.Name() == "upload.mp4") {
+			continue
+		}
+		os.Remove(dir + f.Name())
+	}
+
+	if !keepSource {
+		os.Remove(dir)
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 同时上传源文件的数量
+const SOURCE_UPLOAD_CONCURRENCY = 2
+
+// 本地和OSS都找不到源文件时等待的时间，源文件可能在其他API节点上
+const SOURCE_UPLOAD_TIMEOUT = time.Hour * time.Duration(1)
+
+// 唤醒空闲的上传协程
+var sourceUploadNotify = make(chan struct{}, 1)
+
+// 创建转码任务，使用OSS时先由上传协程将源文件上传到OSS，完成后再加入转码队列
+func createTranscodingTask(task *model.TranscodingTask) error {
+	task.Status = global.TRANSCODING_QUEUED
+	if global.Config.Storage.OssType != "local" {
+		task.Status = global.TRANSCODING_UPLOADING
+	}
+	task.NextRunAt = time.Now()
+	if err := global.Mysql.Create(task).Error; err != nil {
+		utils.ErrorLog("创建转码任务失败", "transcoding", err.Error())
+		return errors.New("创建转码任务失败")
+	}
+
+	if task.Status == global.TRANSCODING_QUEUED {
+		notifyTranscodingWorker(task.ID)
+	} else {
+		select {
+		case sourceUploadNotify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// 启动源文件上传协程，需要在接收视频上传的API服务中运行
+func InitSourceUploader() {
+	if global.Config.Storage.OssType == "local" {
+		return
+	}
+
+	for i := 0; i < SOURCE_UPLOAD_CONCURRENCY; i++ {
+		go sourceUploader()
+	}
+}
+
+func sourceUploader() {
+	for {
+		for {
+			task, ok := claimSourceUploadTask()
+			if !ok {
+				break
+			}
+			runSourceUploadTask(task)
+		}
+
+		// 等待新任务通知，超时后重新检查任务表，处理其他进程创建的任务
+		select {
+		case <-sourceUploadNotify:
+		case <-time.After(TRANSCODING_POLL_INTERVAL):
+		}
+	}
+}
+
+// 领取一个等待上传源文件的任务，心跳超时的任务可以被重新领取
+func claimSourceUploadTask() (model.TranscodingTask, bool) {
+	now := time.Now()
+	stale := now.Add(-TRANSCODING_HEARTBEAT_TIMEOUT)
+	var tasks []model.TranscodingTask
+	global.Mysql.Where("status = ? and next_run_at <= ? and (heartbeat_at is null or heartbeat_at < ?)",
+		global.TRANSCODING_UPLOADING, now, stale).Order("id").Limit(10).Find(&tasks)
+
+	for _, task := range tasks {
+		// 通过心跳条件更新保证同一任务只会被一个协程上传
+		result := global.Mysql.Model(&model.TranscodingTask{}).
+			Where("id = ? and status = ? and (heartbeat_at is null or heartbeat_at < ?)", task.ID, global.TRANSCODING_UPLOADING, stale).
+			Updates(map[string]interface{}{
+				"worker":       transcodingWorkerName,
+				"heartbeat_at": now,
+			})
+		if result.Error == nil && result.RowsAffected == 1 {
+			return task, true
+		}
+	}
+
+	return model.TranscodingTask{}, false
+}
+
+// 上传任务的源文件，完成后加入转码队列
+func runSourceUploadTask(task model.TranscodingTask) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go keepSourceUploadHeartbeat(ctx, task.ID)
+
+	objectKey := "video/" + task.DirName + "/upload.mp4"
+	if !utils.IsFileExists("./upload/" + objectKey) {
+		if exists, err := global.Storage.IsExists(objectKey); err != nil || !exists {
+			if time.Since(task.CreatedAt) < SOURCE_UPLOAD_TIMEOUT {
+				// 源文件可能在其他API节点上，释放任务等待其他节点上传
+				updateUploadingTask(task.ID, map[string]interface{}{
+					"heartbeat_at": nil,
+					"next_run_at":  time.Now().Add(TRANSCODING_POLL_INTERVAL),
+				})
+				return
+			}
+			failSourceUploadTask(task, errors.New("源视频文件不存在"))
+			return
+		}
+	}
+
+	if err := uploadSourceVideo(task.DirName); err != nil {
+		retries := task.Retries + 1
+		if retries > global.Config.Transcoding.MaxRetry {
+			failSourceUploadTask(task, err)
+			return
+		}
+
+		// 指数退避后重新上传
+		updateUploadingTask(task.ID, map[string]interface{}{
+			"retries":      retries,
+			"heartbeat_at": nil,
+			"next_run_at":  time.Now().Add(TRANSCODING_RETRY_BACKOFF * time.Duration(1<<(retries-1))),
+			"error":        err.Error(),
+		})
+		return
+	}
+
+	// 上传的重试次数不计入转码
+	if updateUploadingTask(task.ID, map[string]interface{}{
+		"status":       global.TRANSCODING_QUEUED,
+		"retries":      0,
+		"heartbeat_at": nil,
+		"next_run_at":  time.Now(),
+		"error":        "",
+	}) {
+		notifyTranscodingWorker(task.ID)
+	}
+}
+
+// 源文件上传失败，任务标记为失败
+func failSourceUploadTask(task model.TranscodingTask, err error) {
+	utils.ErrorLog("源视频上传失败", "transcoding", err.Error())
+	if !updateUploadingTask(task.ID, map[string]interface{}{
+		"status": global.TRANSCODING_FAILED,
+		"error":  err.Error(),
+	}) {
+		return
+	}
+
+	// 重新转码不改变资源状态
+	if task.Retranscode {
+		cache.DelTranscodingProgress(task.ResourceID)
+	} else {
+		completeTransCoding(task.Vid, task.ResourceID, global.PROCESSING_FAIL)
+	}
+}
+
+// 更新上传中的任务，任务已被取消时返回false
+func updateUploadingTask(taskId uint, values map[string]interface{}) bool {
+	result := global.Mysql.Model(&model.TranscodingTask{}).
+		Where("id = ? and status = ?", taskId, global.TRANSCODING_UPLOADING).Updates(values)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// 上传期间定时更新心跳，避免任务被其他协程重复领取
+func keepSourceUploadHeartbeat(ctx context.Context, taskId uint) {
+	ticker := time.NewTicker(TRANSCODING_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			updateUploadingTask(taskId, map[string]interface{}{"heartbeat_at": time.Now()})
+		}
+	}
+}
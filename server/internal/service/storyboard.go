@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	STORYBOARD_INTERVAL = 5   // 缩略图间隔（秒）
+	STORYBOARD_WIDTH    = 160 // 缩略图宽度
+	STORYBOARD_COLUMNS  = 10  // 每张雪碧图的列数
+	STORYBOARD_ROWS     = 10  // 每张雪碧图的行数
+)
+
+// 生成缩略图雪碧图及WebVTT，缩略图信息在任务提交时保存
+func generateStoryboard(ctx context.Context, transcodingInfo *dto.TranscodingInfo, logger *transcodingLogger) (*model.Storyboard, error) {
+	if transcodingInfo.Width <= 0 || transcodingInfo.Height <= 0 || transcodingInfo.Duration <= 0 {
+		return nil, errors.New("视频信息有误")
+	}
+
+	// 高度按比例计算并取偶数
+	width := STORYBOARD_WIDTH
+	height := int(math.Round(float64(width*transcodingInfo.Height)/float64(transcodingInfo.Width)/2)) * 2
+
+	filter := fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", STORYBOARD_INTERVAL, width, height, STORYBOARD_COLUMNS, STORYBOARD_ROWS)
+	command := []string{"-i", transcodingInfo.InputFile}
+	if len(transcodingInfo.Clips) > 0 {
+		// 缩略图与剪辑后的时间轴对应
+		command = append(command, clipFilterArgs("[0:"+strconv.Itoa(transcodingInfo.StreamIdx)+"]", transcodingInfo.Clips, false, filter)...)
+	} else {
+		command = append(command, "-vf", filter)
+	}
+	command = append(command, "-q:v", "5", "-y", transcodingInfo.OutputDir+"storyboard_%03d.jpg")
+	cmd := exec.CommandContext(ctx, "ffmpeg", command...)
+	utils.SetProcessGroup(cmd)
+	stderr := logger.captureStderr(cmd)
+	start := time.Now()
+	_, err := utils.RunCmd(cmd)
+	logger.record(TRANSCODING_STAGE_STORYBOARD, "", cmd, start, stderr.String(), err)
+	if err != nil {
+		utils.ErrorLog("生成缩略图失败", "transcoding", err.Error())
+		return nil, err
+	}
+
+	return &model.Storyboard{
+		ResourceID: transcodingInfo.ResourceID,
+		DirName:    transcodingInfo.DirName,
+		Content:    buildStoryboardVTT(transcodingInfo.Duration, width, height),
+	}, nil
+}
+
+// 生成缩略图WebVTT，每个时间段对应雪碧图中的一个区域
+func buildStoryboardVTT(duration float64, width, height int) string {
+	perSheet := STORYBOARD_COLUMNS * STORYBOARD_ROWS
+	count := int(math.Ceil(duration / STORYBOARD_INTERVAL))
+
+	var builder strings.Builder
+	builder.WriteString("WEBVTT\n\n")
+	for i := 0; i < count; i++ {
+		start := float64(i * STORYBOARD_INTERVAL)
+		end := math.Min(start+STORYBOARD_INTERVAL, duration)
+		sheet := i / perSheet
+		x := (i % perSheet % STORYBOARD_COLUMNS) * width
+		y := (i % perSheet / STORYBOARD_COLUMNS) * height
+
+		// ffmpeg输出的图片序号从1开始
+		builder.WriteString(formatVTTTimestamp(start) + " --> " + formatVTTTimestamp(end) + "\n")
+		builder.WriteString(fmt.Sprintf("storyboard_%03d.jpg#xywh=%d,%d,%d,%d\n\n", sheet+1, x, y, width, height))
+	}
+
+	return builder.String()
+}
+
+// 获取缩略图WebVTT
+func GetStoryboard(ctx *gin.Context, resourceId uint) (string, error) {
+	if !IsResourceExist(resourceId) {
+		return "", errors.New("资源不存在")
+	}
+
+	var storyboard model.Storyboard
+	global.Mysql.Where("resource_id = ?", resourceId).First(&storyboard)
+	if storyboard.ID == 0 {
+		return "", errors.New("缩略图不存在")
+	}
+
+	res := ""
+	key := uuid.New().String()
+	cache.SetVideoSlice(key, storyboard.DirName)
+	for _, line := range strings.Split(storyboard.Content, "\n") {
+		if file, fragment, found := strings.Cut(line, "#xywh="); found {
+			res += "/api/v1/video/slice/" + file + "?key=" + key + "#xywh=" + fragment + "\n"
+		} else {
+			res += line + "\n"
+		}
+	}
+
+	return res, nil
+}
+
+// 填充资源的缩略图地址
+func fillResourceStoryboard(resources []vo.ResourceResp) {
+	if len(resources) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(resources))
+	for _, r := range resources {
+		ids = append(ids, r.ID)
+	}
+
+	var resourceIds []uint
+	global.Mysql.Model(&model.Storyboard{}).Where("resource_id in ?", ids).Pluck("resource_id", &resourceIds)
+	for i := range resources {
+		if utils.IsUintInSlice(resourceIds, resources[i].ID) {
+			resources[i].Storyboard = "/api/v1/video/getStoryboard?resourceId=" + utils.UintToString(resources[i].ID)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 字幕文件大小限制(MB)
+const SUBTITLE_MAX_SIZE = 2
+
+// 字幕的完整WebVTT文件
+const SUBTITLE_VTT_FILE = "subtitle.vtt"
+
+// ffmpeg输出的MPEG-TS默认起始时间戳为1.4秒(90kHz)，用于没有记录切片起始时间的旧数据
+const SUBTITLE_MPEGTS_OFFSET = 126000
+
+// 字幕文件后缀对应的ffmpeg格式，避免ffmpeg按内容探测格式
+var subtitleFormats = map[string]string{
+	".srt": "srt",
+	".ass": "ass",
+	".ssa": "ass",
+	".vtt": "webvtt",
+}
+
+// 语言代码，如 zh-CN、en
+var subtitleLangRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+type subtitleCue struct {
+	Start    float64
+	End      float64
+	Settings string
+	Text     string
+}
+
+// 上传字幕
+func UploadSubtitle(ctx *gin.Context, resourceId uint, lang, name string, file *multipart.FileHeader) (vo.SubtitleResp, error) {
+	suffix := path.Ext(file.Filename)
+	if !utils.IsSubtitleType(suffix) {
+		return vo.SubtitleResp{}, errors.New("文件类型错误")
+	}
+	if !utils.FileSize(file.Size, 1, SUBTITLE_MAX_SIZE) {
+		return vo.SubtitleResp{}, errors.New("文件大小超出限制")
+	}
+	if !subtitleLangRegexp.MatchString(lang) {
+		return vo.SubtitleResp{}, errors.New("语言代码有误")
+	}
+	if name == "" {
+		name = lang
+	}
+	if utf8.RuneCountInString(name) > 50 {
+		return vo.SubtitleResp{}, errors.New("字幕名称过长")
+	}
+
+	userId := ctx.GetUint("userId")
+	var resource model.Resource
+	global.Mysql.Model(&model.Resource{}).Where("id = ? and uid = ?", resourceId, userId).First(&resource)
+	if resource.ID == 0 {
+		return vo.SubtitleResp{}, errors.New("资源不存在")
+	}
+
+	dirName := generateVideoFilename()
+	outputDir := "./upload/video/" + dirName + "/"
+	sourceFile := outputDir + "source" + strings.ToLower(suffix)
+	if err := ctx.SaveUploadedFile(file, sourceFile); err != nil {
+		return vo.SubtitleResp{}, errors.New("文件上传失败")
+	}
+	defer os.Remove(sourceFile)
+
+	content, err := processSubtitle(sourceFile, outputDir, resource)
+	if err != nil {
+		os.RemoveAll(outputDir)
+		return vo.SubtitleResp{}, err
+	}
+
+	// 上传oss
+	if err := uploadSubtitleFiles(dirName); err != nil {
+		return vo.SubtitleResp{}, errors.New("字幕上传失败")
+	}
+
+	// 同一语言只保留最新的字幕
+	subtitle := model.Subtitle{
+		ResourceID: resourceId,
+		Uid:        userId,
+		Lang:       lang,
+		Name:       name,
+		DirName:    dirName,
+		Content:    content,
+	}
+	var replaced []model.Subtitle
+	if err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("resource_id = ? and lang = ?", resourceId, lang).Find(&replaced).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("resource_id = ? and lang = ?", resourceId, lang).Delete(&model.Subtitle{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&subtitle).Error
+	}); err != nil {
+		utils.ErrorLog("保存字幕失败", "subtitle", err.Error())
+		return vo.SubtitleResp{}, errors.New("保存字幕失败")
+	}
+	go removeSubtitleFiles(replaced)
+
+	return subtitleToSubtitleResp(subtitle), nil
+}
+
+// 上传字幕文件到OSS
+func uploadSubtitleFiles(dirName string) error {
+	if global.Config.Storage.OssType == "local" {
+		return nil
+	}
+
+	files, err := os.ReadDir("./upload/video/" + dirName)
+	if err != nil {
+		utils.ErrorLog("读取字幕文件夹失败", "oss", err.Error())
+		return err
+	}
+	for _, f := range files {
+		if path.Ext(f.Name()) != ".vtt" {
+			continue
+		}
+		objectKey := "video/" + dirName + "/" + f.Name()
+		if err := global.Storage.PutObjectFromFile(objectKey, "./upload/"+objectKey); err != nil {
+			utils.ErrorLog("文件上传OSS失败", "oss", err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 重新生成资源的字幕切片，用于剪辑及重新转码后与新的视频切片对齐
+// remap不为空时调整字幕时间轴，返回false的字幕将被丢弃
+func regenerateSubtitles(resource model.Resource, remap func(cue subtitleCue) (subtitleCue, bool)) {
+	var subtitles []model.Subtitle
+	global.Mysql.Where("resource_id = ?", resource.ID).Find(&subtitles)
+	for _, subtitle := range subtitles {
+		if err := regenerateSubtitle(resource, subtitle, remap); err != nil {
+			utils.ErrorLog("重新生成字幕失败", "subtitle", utils.UintToString(subtitle.ID)+" "+err.Error())
+		}
+	}
+}
+
+// 从字幕的完整WebVTT重新切片，输出到新目录后替换
+func regenerateSubtitle(resource model.Resource, subtitle model.Subtitle, remap func(cue subtitleCue) (subtitleCue, bool)) error {
+	vttFile := "./upload/video/" + subtitle.DirName + "/" + SUBTITLE_VTT_FILE
+	if !utils.IsFileExists(vttFile) {
+		if global.Config.Storage.OssType == "local" {
+			return errors.New("字幕文件不存在")
+		}
+		if err := os.MkdirAll("./upload/video/"+subtitle.DirName, os.ModePerm); err != nil {
+			return err
+		}
+		if err := global.Storage.GetObjectToFile("video/"+subtitle.DirName+"/"+SUBTITLE_VTT_FILE, vttFile); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(vttFile)
+	if err != nil {
+		return err
+	}
+	cues, err := parseWebVTT(string(data))
+	if err != nil {
+		return err
+	}
+	if remap != nil {
+		remapped := make([]subtitleCue, 0, len(cues))
+		for _, cue := range cues {
+			if cue, ok := remap(cue); ok {
+				remapped = append(remapped, cue)
+			}
+		}
+		cues = remapped
+	}
+
+	dirName := generateVideoFilename()
+	outputDir := "./upload/video/" + dirName + "/"
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(outputDir+SUBTITLE_VTT_FILE, []byte(buildSubtitleVTT(cues)), 0644); err != nil {
+		os.RemoveAll(outputDir)
+		return err
+	}
+	duration := resource.Duration
+	if duration <= 0 && len(cues) > 0 {
+		duration = cues[len(cues)-1].End
+	}
+	content, err := segmentSubtitle(cues, duration, outputDir, getSubtitleTimestampOffset(resource.ID))
+	if err == nil {
+		err = uploadSubtitleFiles(dirName)
+	}
+	if err != nil {
+		removeTranscodingFiles(dirName, false)
+		return err
+	}
+
+	// 字幕在此期间被替换或删除时放弃本次结果
+	result := global.Mysql.Model(&model.Subtitle{}).Where("id = ? and dir_name = ?", subtitle.ID, subtitle.DirName).
+		Updates(map[string]interface{}{"dir_name": dirName, "content": content})
+	if result.Error != nil || result.RowsAffected == 0 {
+		removeTranscodingFiles(dirName, false)
+		if result.Error != nil {
+			return result.Error
+		}
+		return nil
+	}
+	removeTranscodingFiles(subtitle.DirName, false)
+
+	return nil
+}
+
+// 删除字幕
+func DeleteSubtitle(ctx *gin.Context, id uint) error {
+	userId := ctx.GetUint("userId")
+	var subtitle model.Subtitle
+	global.Mysql.Where("id = ? and uid = ?", id, userId).First(&subtitle)
+	if subtitle.ID == 0 {
+		return errors.New("字幕不存在")
+	}
+
+	if err := global.Mysql.Where("id = ?", id).Delete(&model.Subtitle{}).Error; err != nil {
+		utils.ErrorLog("删除字幕失败", "subtitle", err.Error())
+		return errors.New("删除字幕失败")
+	}
+	go removeSubtitleFiles([]model.Subtitle{subtitle})
+
+	return nil
+}
+
+// 删除资源的所有字幕
+func deleteResourceSubtitles(resourceId uint) {
+	var subtitles []model.Subtitle
+	global.Mysql.Where("resource_id = ?", resourceId).Find(&subtitles)
+	if len(subtitles) == 0 {
+		return
+	}
+
+	global.Mysql.Where("resource_id = ?", resourceId).Delete(&model.Subtitle{})
+	removeSubtitleFiles(subtitles)
+}
+
+// 删除字幕的分段文件，每个字幕使用单独的目录
+func removeSubtitleFiles(subtitles []model.Subtitle) {
+	for _, subtitle := range subtitles {
+		if subtitle.DirName != "" {
+			removeTranscodingFiles(subtitle.DirName, false)
+		}
+	}
+}
+
+// 获取资源的字幕列表
+func GetSubtitleList(ctx *gin.Context, resourceId uint) ([]vo.SubtitleResp, error) {
+	userId := ctx.GetUint("userId")
+	var resource model.Resource
+	global.Mysql.Model(&model.Resource{}).Select("id").Where("id = ? and uid = ?", resourceId, userId).First(&resource)
+	if resource.ID == 0 {
+		return nil, errors.New("资源不存在")
+	}
+
+	return getSubtitleList(resourceId), nil
+}
+
+// 获取字幕播放列表
+func GetSubtitleFile(ctx *gin.Context, id uint) (string, error) {
+	var subtitle model.Subtitle
+	global.Mysql.Where("id = ?", id).First(&subtitle)
+	if subtitle.ID == 0 || !IsResourceExist(subtitle.ResourceID) {
+		return "", errors.New("字幕不存在")
+	}
+
+	return rewritePlaylist(subtitle.Content, subtitle.DirName, subtitle.ResourceID), nil
+}
+
+// 获取字幕播放列表（后台管理）
+func GetSubtitleFileManage(ctx *gin.Context, id uint) (string, error) {
+	var subtitle model.Subtitle
+	global.Mysql.Where("id = ?", id).First(&subtitle)
+	if subtitle.ID == 0 {
+		return "", errors.New("字幕不存在")
+	}
+
+	return rewritePlaylist(subtitle.Content, subtitle.DirName, subtitle.ResourceID), nil
+}
+
+func getSubtitleList(resourceId uint) []vo.SubtitleResp {
+	var subtitles []model.Subtitle
+	global.Mysql.Where("resource_id = ?", resourceId).Order("id").Find(&subtitles)
+
+	res := make([]vo.SubtitleResp, 0, len(subtitles))
+	for _, s := range subtitles {
+		res = append(res, subtitleToSubtitleResp(s))
+	}
+
+	return res
+}
+
+// 填充资源的字幕信息
+func fillResourceSubtitles(resources []vo.ResourceResp) {
+	if len(resources) == 0 {
+		return
+	}
+
+	ids := make([]uint, 0, len(resources))
+	for _, r := range resources {
+		ids = append(ids, r.ID)
+	}
+
+	var subtitles []model.Subtitle
+	global.Mysql.Where("resource_id in ?", ids).Order("id").Find(&subtitles)
+	for i := range resources {
+		for _, s := range subtitles {
+			if s.ResourceID == resources[i].ID {
+				resources[i].Subtitles = append(resources[i].Subtitles, subtitleToSubtitleResp(s))
+			}
+		}
+	}
+}
+
+func subtitleToSubtitleResp(subtitle model.Subtitle) vo.SubtitleResp {
+	return vo.SubtitleResp{
+		ID:         subtitle.ID,
+		CreatedAt:  subtitle.CreatedAt,
+		ResourceID: subtitle.ResourceID,
+		Lang:       subtitle.Lang,
+		Name:       subtitle.Name,
+	}
+}
+
+// 转换为WebVTT并切片，返回字幕播放列表
+func processSubtitle(sourceFile, outputDir string, resource model.Resource) (string, error) {
+	format, ok := subtitleFormats[strings.ToLower(path.Ext(sourceFile))]
+	if !ok {
+		return "", errors.New("文件类型错误")
+	}
+
+	// 只允许读取本地文件，字幕中的引用不能访问网络
+	vttFile := outputDir + SUBTITLE_VTT_FILE
+	command := []string{"-protocol_whitelist", "file", "-f", format, "-i", sourceFile, "-f", "webvtt", "-y", vttFile}
+	if _, err := utils.RunCmd(exec.Command("ffmpeg", command...)); err != nil {
+		utils.ErrorLog("字幕转换失败", "subtitle", err.Error())
+		return "", errors.New("字幕文件格式有误")
+	}
+
+	data, err := os.ReadFile(vttFile)
+	if err != nil {
+		return "", errors.New("字幕文件格式有误")
+	}
+	cues, err := parseWebVTT(string(data))
+	if err != nil {
+		return "", err
+	}
+
+	duration := resource.Duration
+	if duration <= 0 {
+		duration = cues[len(cues)-1].End
+	}
+
+	return segmentSubtitle(cues, duration, outputDir, getSubtitleTimestampOffset(resource.ID))
+}
+
+// 解析WebVTT
+func parseWebVTT(content string) ([]subtitleCue, error) {
+	content = strings.ReplaceAll(strings.TrimPrefix(content, "\ufeff"), "\r\n", "\n")
+	if !strings.HasPrefix(content, "WEBVTT") {
+		return nil, errors.New("字幕文件格式有误")
+	}
+
+	cues := make([]subtitleCue, 0)
+	for _, block := range strings.Split(content, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, line := range lines {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+
+			parts := strings.Fields(line)
+			if len(parts) < 3 || parts[1] != "-->" {
+				return nil, errors.New("字幕时间轴有误")
+			}
+			start, err1 := parseVTTTimestamp(parts[0])
+			end, err2 := parseVTTTimestamp(parts[2])
+			if err1 != nil || err2 != nil || end < start {
+				return nil, errors.New("字幕时间轴有误")
+			}
+
+			cues = append(cues, subtitleCue{
+				Start:    start,
+				End:      end,
+				Settings: strings.Join(parts[3:], " "),
+				Text:     strings.Join(lines[i+1:], "\n"),
+			})
+			break
+		}
+	}
+
+	if len(cues) == 0 {
+		return nil, errors.New("字幕内容为空")
+	}
+
+	return cues, nil
+}
+
+// 解析时间戳 (hh:mm:ss.mmm 或 mm:ss.mmm)
+func parseVTTTimestamp(timestamp string) (float64, error) {
+	parts := strings.Split(timestamp, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, errors.New("invalid timestamp")
+	}
+
+	seconds := 0.0
+	for _, part := range parts[:len(parts)-1] {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, err
+		}
+		seconds = seconds*60 + float64(v)
+	}
+	last, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return seconds*60 + last, nil
+}
+
+// 生成完整的WebVTT
+func buildSubtitleVTT(cues []subtitleCue) string {
+	var builder strings.Builder
+	builder.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		builder.WriteString(formatVTTTimestamp(cue.Start) + " --> " + formatVTTTimestamp(cue.End))
+		if cue.Settings != "" {
+			builder.WriteString(" " + cue.Settings)
+		}
+		builder.WriteString("\n" + cue.Text + "\n\n")
+	}
+
+	return builder.String()
+}
+
+func formatVTTTimestamp(seconds float64) string {
+	ms := int(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// 与视频切片对齐的时间戳偏移，使用转码时记录的首个切片起始时间戳
+func getSubtitleTimestampOffset(resourceId uint) int {
+	packaging := global.Config.Transcoding.Packaging
+	var indexFile model.VideoIndexFile
+	global.Mysql.Where("resource_id = ? and quality <> ?", resourceId, AUDIO_QUALITY).Limit(1).Find(&indexFile)
+	if indexFile.ID != 0 {
+		if indexFile.StartPts != nil {
+			return *indexFile.StartPts
+		}
+		packaging = global.PACKAGING_TS
+		if parseInitSegment(indexFile.Content) != "" {
+			packaging = global.PACKAGING_CMAF
+		}
+	}
+
+	if packaging == global.PACKAGING_CMAF {
+		return 0
+	}
+	return SUBTITLE_MPEGTS_OFFSET
+}
+
+// 按视频切片时长切分字幕
+func segmentSubtitle(cues []subtitleCue, duration float64, outputDir string, offset int) (string, error) {
+	count := int(math.Ceil(duration / HLS_SEGMENT_TIME))
+	if count == 0 {
+		count = 1
+	}
+
+	var playlist strings.Builder
+	playlist.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:" + strconv.Itoa(HLS_SEGMENT_TIME) + "\n")
+	playlist.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for i := 0; i < count; i++ {
+		start := float64(i * HLS_SEGMENT_TIME)
+		end := math.Min(start+HLS_SEGMENT_TIME, duration)
+		last := i == count-1
+
+		var segment strings.Builder
+		segment.WriteString("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:" + strconv.Itoa(offset) + ",LOCAL:00:00:00.000\n\n")
+		for _, cue := range cues {
+			// 跨切片的字幕在每个切片中重复出现
+			if cue.End <= start || (cue.Start >= end && !last) {
+				continue
+			}
+			segment.WriteString(formatVTTTimestamp(cue.Start) + " --> " + formatVTTTimestamp(cue.End))
+			if cue.Settings != "" {
+				segment.WriteString(" " + cue.Settings)
+			}
+			segment.WriteString("\n" + cue.Text + "\n\n")
+		}
+
+		segmentName := fmt.Sprintf("subtitle_%05d.vtt", i)
+		if err := os.WriteFile(outputDir+segmentName, []byte(segment.String()), 0644); err != nil {
+			utils.ErrorLog("写入字幕切片失败", "subtitle", err.Error())
+			return "", errors.New("字幕切片失败")
+		}
+		playlist.WriteString(fmt.Sprintf("#EXTINF:%.3f,\n%s\n", end-start, segmentName))
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	return playlist.String(), nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWebVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []subtitleCue
+		wantErr bool
+	}{
+		{
+			name:    "标准格式",
+			content: "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\n第一句\n\n00:01:00.000 --> 00:01:03.250\n第二句\n第二行\n",
+			want: []subtitleCue{
+				{Start: 1, End: 2.5, Text: "第一句"},
+				{Start: 60, End: 63.25, Text: "第二句\n第二行"},
+			},
+		},
+		{
+			name:    "BOM、CRLF、编号及样式",
+			content: "\ufeffWEBVTT\r\n\r\n1\r\n01:00.500 --> 01:02.000 align:start line:0\r\n字幕\r\n",
+			want: []subtitleCue{
+				{Start: 60.5, End: 62, Settings: "align:start line:0", Text: "字幕"},
+			},
+		},
+		{
+			name:    "跳过NOTE",
+			content: "WEBVTT\n\nNOTE 注释\n\n00:00:00.000 --> 00:00:01.000\n字幕\n",
+			want:    []subtitleCue{{Start: 0, End: 1, Text: "字幕"}},
+		},
+		{
+			name:    "缺少文件头",
+			content: "00:00:01.000 --> 00:00:02.000\n字幕\n",
+			wantErr: true,
+		},
+		{
+			name:    "结束时间早于开始时间",
+			content: "WEBVTT\n\n00:00:02.000 --> 00:00:01.000\n字幕\n",
+			wantErr: true,
+		},
+		{
+			name:    "时间格式错误",
+			content: "WEBVTT\n\n00:aa:01.000 --> 00:00:02.000\n字幕\n",
+			wantErr: true,
+		},
+		{
+			name:    "没有字幕",
+			content: "WEBVTT\n\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWebVTT(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWebVTT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWebVTT() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
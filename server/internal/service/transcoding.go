@@ -1,26 +1,59 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/config"
 	"interastral-peace.com/alnitak/internal/domain/dto"
 	"interastral-peace.com/alnitak/internal/domain/model"
 	"interastral-peace.com/alnitak/internal/global"
 	"interastral-peace.com/alnitak/utils"
 )
 
+// HLS切片时长（秒）
+const HLS_SEGMENT_TIME = 10
+
+// 转码选项
+type transcodingOptions struct {
+	Packaging   string            // 封装格式
+	KeyInfoFile string            // 加密密钥信息文件，为空时不加密
+	StreamIdx   int               // 视频流序号
+	ToneMap     bool              // 是否将HDR映射为SDR
+	HasAudio    bool              // 是否有音频
+	AudioFilter string            // 音频滤镜，如响度标准化
+	Watermark   *watermarkOptions // 水印，为空时不添加
+	Clips       []dto.ClipRange   // 剪辑保留的片段，为空时使用完整视频
+}
+
 type TranscodingTarget struct {
-	Resolution  string // 分辨率
-	BitrateRate string // 码率
-	FPS         string // 帧率
-	FpsName     string // 帧率名称
+	Resolution   string // 分辨率
+	BitrateRate  string // 码率
+	FPS          string // 帧率
+	FpsName      string // 帧率名称
+	Codec        string // 视频编码器
+	Encoder      videoEncoder
+	Crf          int    // 质量因子
+	Preset       string // 编码预设
+	AudioBitrate string // 音频码率
+}
+
+// 转码结果，在任务提交时写入数据库
+type transcodingResult struct {
+	IndexFiles []model.VideoIndexFile
+	VideoKey   *model.VideoKey   // 切片加密密钥，未加密时为空
+	Storyboard *model.Storyboard // 缩略图，生成失败时为空
+	Clip       *clipResult       // 剪辑结果，非剪辑任务为空
 }
 
 // 生成封面
@@ -35,132 +68,232 @@ func GenerateCover(inputFile, outputFile string) error {
 	return nil
 }
 
+// 文件中没有可解码的视频流
+var ErrNoVideoStream = errors.New("文件中没有可用的视频流")
+
 // 获取视频信息
 func ProcessVideoInfo(input string) (*dto.TranscodingInfo, error) {
+	return probeVideoInfo(input, nil)
+}
+
+// 获取视频信息，并记录执行的命令
+func probeVideoInfo(input string, logger *transcodingLogger) (*dto.TranscodingInfo, error) {
 	var transcodingInfo dto.TranscodingInfo
-	videoData, err := getVideoInfo(input)
+	videoData, err := getVideoInfo(input, logger)
 	if err != nil {
 		utils.ErrorLog("读取视频信息失败", "transcoding", err.Error())
 		return &transcodingInfo, err
 	}
 
-	// 计算最大分辨率
-	transcodingInfo.Width = videoData.Stream[0].Width
-	transcodingInfo.Height = videoData.Stream[0].Height
-	transcodingInfo.CodecName = videoData.Stream[0].CodecName
+	stream, ok := selectVideoStream(videoData)
+	if !ok || !isVideoDecodable(input, stream.Index, logger) {
+		return &transcodingInfo, ErrNoVideoStream
+	}
 
-	// 获取视频时长
-	transcodingInfo.Duration, _ = strconv.ParseFloat(videoData.Stream[0].Duration, 64)
+	// 计算最大分辨率，旋转90度的视频需要交换宽高
+	transcodingInfo.StreamIdx = stream.Index
+	transcodingInfo.Rotation = getRotation(stream)
+	transcodingInfo.Width = stream.Width
+	transcodingInfo.Height = stream.Height
+	if transcodingInfo.Rotation%180 != 0 {
+		transcodingInfo.Width, transcodingInfo.Height = stream.Height, stream.Width
+	}
+	transcodingInfo.CodecName = stream.CodecName
+
+	// 获取视频时长，流中没有时长时使用容器的时长
+	transcodingInfo.Duration, _ = strconv.ParseFloat(stream.Duration, 64)
+	if transcodingInfo.Duration <= 0 {
+		transcodingInfo.Duration, _ = strconv.ParseFloat(videoData.Format.Duration, 64)
+	}
+
+	// 是否有音频
+	for _, s := range videoData.Stream {
+		if s.CodecType == "audio" {
+			transcodingInfo.HasAudio = true
+			break
+		}
+	}
+
+	// 文件中的章节
+	transcodingInfo.Chapters = getProbeChapters(videoData)
+
+	// HDR及位深
+	transcodingInfo.HDR = stream.ColorTransfer == "smpte2084" || stream.ColorTransfer == "arib-std-b67"
+	transcodingInfo.BitDepth = getBitDepth(stream)
 
 	// 获取帧率
-	transcodingInfo.FPS = videoData.Stream[0].AvgFrameRate
+	transcodingInfo.FPS = stream.AvgFrameRate
 	transcodingInfo.FPS30, transcodingInfo.FPS60 = getFpsInfo(transcodingInfo.FPS)
 
 	return &transcodingInfo, nil
 }
 
-func VideoTransCoding(transcodingInfo *dto.TranscodingInfo) {
-	var wg sync.WaitGroup
-	targets := getTranscodingTarget(transcodingInfo)
-	wg.Add(len(targets))
-	for _, v := range targets {
-		c := v // 处理协程引用循环变量问题
-		go func() {
-			fileName := c.Resolution + "_" + c.BitrateRate + "_" + c.FpsName
-			tsFileName := transcodingInfo.OutputDir + fileName + ".ts"
-
-			// 根据配置选择使用 CPU 或 GPU
-			var err error
-			if global.Config.Transcoding.UseGpu {
-				err = pressingVideoGPU(transcodingInfo.InputFile, tsFileName, c.Resolution, c.BitrateRate, c.FPS)
-			} else {
-				err = pressingVideo(transcodingInfo.InputFile, tsFileName, c.Resolution, c.BitrateRate, c.FPS)
-			}
-			if err != nil {
-				wg.Done()
-				return
-			}
-			// 切片
-			m3u8File, err := generateVideoSlices(tsFileName, transcodingInfo.OutputDir, fileName)
-			if err != nil {
-				wg.Done()
-				return
-			}
-			// 读取m3u8写入数据库
-			err = saveM3u8File(transcodingInfo, fileName, m3u8File)
-			if err != nil {
-				wg.Done()
-				return
-			}
+// 选择第一个真正的视频流，跳过封面图片等
+func selectVideoStream(videoData global.VideoInfo) (global.Streams, bool) {
+	for _, stream := range videoData.Stream {
+		if stream.CodecType != "video" || stream.Disposition.AttachedPic == 1 {
+			continue
+		}
+		if stream.CodecName == "" || stream.Width <= 0 || stream.Height <= 0 {
+			continue
+		}
+		return stream, true
+	}
 
-			//删除临时文件
-			os.Remove(tsFileName)
-			os.Remove(m3u8File)
+	return global.Streams{}, false
+}
 
-			wg.Done()
-		}()
+// 尝试解码第一帧
+func isVideoDecodable(input string, streamIdx int, logger *transcodingLogger) bool {
+	command := []string{"-v", "error", "-i", input, "-map", "0:" + strconv.Itoa(streamIdx), "-frames:v", "1", "-f", "null", "-"}
+	cmd := exec.Command("ffmpeg", command...)
+	stderr := logger.captureStderr(cmd)
+	start := time.Now()
+	_, err := utils.RunCmd(cmd)
+	logger.record(TRANSCODING_STAGE_DECODE, "", cmd, start, stderr.String(), err)
+	if err != nil {
+		utils.ErrorLog("视频流无法解码", "transcoding", err.Error())
+		return false
 	}
 
-	wg.Wait()
+	return true
+}
 
-	// 上传oss
-	if global.Config.Storage.OssType != "local" {
-		files, err := os.ReadDir(transcodingInfo.OutputDir)
-		if err != nil {
-			utils.ErrorLog("读取视频文件夹失败", "oss", err.Error())
-			completeTransCoding(transcodingInfo.VideoID, transcodingInfo.ResourceID, global.PROCESSING_FAIL)
-			return
+// 获取旋转角度，优先使用Display Matrix
+func getRotation(stream global.Streams) int {
+	rotation := 0
+	for _, side := range stream.SideDataList {
+		if side.SideDataType == "Display Matrix" {
+			rotation = side.Rotation
+			break
 		}
+	}
+	if rotation == 0 && stream.Tags.Rotate != "" {
+		rotation, _ = strconv.Atoi(stream.Tags.Rotate)
+	}
 
-		for _, f := range files {
-			if f.Name() == "upload.mp4" && !global.Config.Storage.UploadMp4File {
-				continue
-			}
+	// 统一为 0-359
+	return (rotation%360 + 360) % 360
+}
 
-			objectKey := "video/" + transcodingInfo.DirName + "/" + f.Name()
-			filePath := "./upload/" + objectKey
-			if err := global.Storage.PutObjectFromFile(objectKey, filePath); err != nil {
-				utils.ErrorLog("文件上传OSS失败", "oss", err.Error())
-			}
+// 获取位深
+func getBitDepth(stream global.Streams) int {
+	if bits, err := strconv.Atoi(stream.BitsPerRawSample); err == nil && bits > 0 {
+		return bits
+	}
+	for _, depth := range []int{16, 12, 10} {
+		if strings.Contains(stream.PixFmt, "p"+strconv.Itoa(depth)) {
+			return depth
 		}
 	}
 
-	// 更新状态
-	completeTransCoding(transcodingInfo.VideoID, transcodingInfo.ResourceID, global.WAITING_REVIEW)
+	return 8
 }
 
-// 获取宽度支持的最大分辨率
-func getWidthRes(width int) int {
-	//1920*1080
-	if width >= 1920 {
-		return 1080
+// 转码并上传所有输出文件，返回需要在任务提交时写入数据库的结果
+func VideoTransCoding(ctx context.Context, transcodingInfo *dto.TranscodingInfo, logger *transcodingLogger) (*transcodingResult, error) {
+	targets := getTranscodingTarget(transcodingInfo)
+	if len(targets) == 0 {
+		return nil, errors.New("没有可用的转码目标")
 	}
-	// 1280*720
-	if width >= 1280 {
-		return 720
+
+	fileNames := make([]string, len(targets))
+	for i, t := range targets {
+		fileNames[i] = t.Resolution + "_" + t.BitrateRate + "_" + t.FpsName
 	}
-	// 720*480
-	if width >= 720 {
-		return 480
+
+	options := transcodingOptions{
+		Packaging: global.Config.Transcoding.Packaging,
+		StreamIdx: transcodingInfo.StreamIdx,
+		ToneMap:   transcodingInfo.HDR,
+		HasAudio:  transcodingInfo.HasAudio,
+		Clips:     transcodingInfo.Clips,
 	}
-	return 360
+
+	// 水印在缩放前叠加，CPU和GPU编码器共用
+	if options.Watermark = getWatermarkOptions(transcodingInfo); options.Watermark != nil {
+		defer removeWatermarkFile(transcodingInfo.OutputDir)
+	}
+
+	// 有音频时额外输出纯音频流
+	outputNames := fileNames
+	if transcodingInfo.HasAudio {
+		options.AudioFilter = getAudioFilter(ctx, transcodingInfo.InputFile, transcodingInfo.Clips, logger)
+		outputNames = append(append([]string{}, fileNames...), AUDIO_QUALITY)
+	}
+
+	// 生成切片加密密钥
+	var videoKey *model.VideoKey
+	if global.Config.Transcoding.Encrypt {
+		var err error
+		videoKey, err = generateVideoKey(transcodingInfo.OutputDir, transcodingInfo.DirName, transcodingInfo.ResourceID)
+		if err != nil {
+			return nil, err
+		}
+		defer removeVideoKeyFiles(transcodingInfo.OutputDir)
+		options.KeyInfoFile = VIDEO_KEY_INFO_FILE
+	}
+
+	// 单次解码，同时输出所有分辨率的切片
+	onProgress := newProgressReporter(transcodingInfo.VideoID, transcodingInfo.ResourceID, outputNames, transcodingInfo.Duration)
+	if err := pressingVideo(ctx, transcodingInfo, targets, fileNames, options, onProgress, logger); err != nil {
+		return nil, err
+	}
+
+	// 读取m3u8，在任务提交时写入数据库
+	result := &transcodingResult{VideoKey: videoKey}
+	for _, fileName := range outputNames {
+		indexFile, err := readM3u8File(transcodingInfo, fileName)
+		if err != nil {
+			return nil, err
+		}
+		result.IndexFiles = append(result.IndexFiles, indexFile)
+	}
+
+	//删除临时文件
+	for _, fileName := range outputNames {
+		os.Remove(transcodingInfo.OutputDir + fileName + ".m3u8")
+	}
+
+	// 生成缩略图，失败不影响播放
+	result.Storyboard, _ = generateStoryboard(ctx, transcodingInfo, logger)
+
+	// 所有文件上传完成后才能替换数据库中的索引文件
+	if err := uploadTranscodingOutput(ctx, transcodingInfo.DirName); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
-// 获取高度支持的最大分辨率
-func getHeigthRes(height int) int {
-	//1920*1080
-	if height >= 1080 {
-		return 1080
+// 上传转码输出到OSS，任一文件失败时返回错误
+func uploadTranscodingOutput(ctx context.Context, dirName string) error {
+	if global.Config.Storage.OssType == "local" {
+		return nil
 	}
-	// 1280*720
-	if height >= 720 {
-		return 720
+
+	files, err := os.ReadDir("./upload/video/" + dirName)
+	if err != nil {
+		utils.ErrorLog("读取视频文件夹失败", "oss", err.Error())
+		return err
 	}
-	// 720*480
-	if height >= 480 {
-		return 480
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if f.IsDir() || !isTranscodingOutput(f.Name()) {
+			continue
+		}
+
+		objectKey := "video/" + dirName + "/" + f.Name()
+		if err := global.Storage.PutObjectFromFile(objectKey, "./upload/"+objectKey); err != nil {
+			utils.ErrorLog("文件上传OSS失败", "oss", err.Error())
+			return err
+		}
 	}
-	return 360
+
+	return nil
 }
 
 // 获取帧率信息
@@ -189,32 +322,94 @@ func getFpsInfo(avgFrameRate string) (string, string) {
 // 获取转码目标
 func getTranscodingTarget(videoInfo *dto.TranscodingInfo) []TranscodingTarget {
 	targets := make([]TranscodingTarget, 0)
-	maxRresolution := utils.Max(getWidthRes(videoInfo.Width), getHeigthRes(videoInfo.Height))
+	ladder := global.Config.Transcoding.Ladder
+	longSide := utils.Max(videoInfo.Width, videoInfo.Height)
+	shortSide := utils.Min(videoInfo.Width, videoInfo.Height)
+	for _, rung := range ladder {
+		// 源视频长边或短边达到该档位时才生成，避免放大
+		if longSide < rung.Width && shortSide < rung.Height {
+			continue
+		}
 
-	switch maxRresolution {
-	case 1080:
-		if global.Config.Transcoding.Generate1080p60 && videoInfo.FPS60 != "" {
-			targets = append(targets, TranscodingTarget{Resolution: "1920x1080", BitrateRate: "6000k", FPS: videoInfo.FPS60, FpsName: "60"})
+		if target, ok := newTranscodingTarget(videoInfo, rung); ok {
+			targets = append(targets, target)
+		}
+	}
+
+	// 源视频小于所有档位时使用最低档位
+	if len(targets) == 0 && len(ladder) > 0 {
+		lowest := ladder[0]
+		for _, rung := range ladder {
+			if rung.Width*rung.Height < lowest.Width*lowest.Height {
+				lowest = rung
+			}
+		}
+		if target, ok := newTranscodingTarget(videoInfo, lowest); ok {
+			targets = append(targets, target)
 		}
-		targets = append(targets, TranscodingTarget{Resolution: "1920x1080", BitrateRate: "3000k", FPS: videoInfo.FPS30, FpsName: "30"})
-		fallthrough
-	case 720:
-		targets = append(targets, TranscodingTarget{Resolution: "1280x720", BitrateRate: "2000k", FPS: videoInfo.FPS30, FpsName: "30"})
-		fallthrough
-	case 480:
-		targets = append(targets, TranscodingTarget{Resolution: "854x480", BitrateRate: "900k", FPS: videoInfo.FPS30, FpsName: "30"})
-		fallthrough
-	case 360:
-		targets = append(targets, TranscodingTarget{Resolution: "640x360", BitrateRate: "500k", FPS: videoInfo.FPS30, FpsName: "30"})
 	}
 
 	return targets
 }
 
+// 档位在输出文件名中的帧率，30帧及以下的档位都使用30
+func getRungFpsName(rung config.TranscodingRung) string {
+	if rung.MaxFps > 30 {
+		return strconv.Itoa(rung.MaxFps)
+	}
+	return "30"
+}
+
+func newTranscodingTarget(videoInfo *dto.TranscodingInfo, rung config.TranscodingRung) (TranscodingTarget, bool) {
+	// 竖屏视频使用竖屏分辨率
+	resolution := fmt.Sprintf("%dx%d", rung.Width, rung.Height)
+	if videoInfo.Height > videoInfo.Width {
+		resolution = fmt.Sprintf("%dx%d", rung.Height, rung.Width)
+	}
+
+	target := TranscodingTarget{
+		Resolution:   resolution,
+		BitrateRate:  rung.Bitrate,
+		FPS:          videoInfo.FPS30,
+		FpsName:      "30",
+		Codec:        rung.Codec,
+		Crf:          rung.Crf,
+		Preset:       rung.Preset,
+		AudioBitrate: rung.AudioBitrate,
+	}
+
+	// 高帧率档位
+	if rung.MaxFps > 30 {
+		if !global.Config.Transcoding.Generate1080p60 || videoInfo.FPS60 == "" {
+			return target, false
+		}
+		target.FPS = videoInfo.FPS60
+		target.FpsName = getRungFpsName(rung)
+	}
+
+	if target.Codec == "" {
+		target.Codec = "libx264"
+	}
+	encoder, ok := getVideoEncoder(target.Codec, global.Config.Transcoding.UseGpu)
+	if !ok {
+		utils.ErrorLog("不支持的编码器", "transcoding", target.Codec)
+		return target, false
+	}
+	target.Encoder = encoder
+	if target.AudioBitrate == "" {
+		target.AudioBitrate = "128k"
+	}
+
+	return target, true
+}
+
 // 获取视频信息
-func getVideoInfo(input string) (info global.VideoInfo, err error) {
-	cmd := exec.Command("ffprobe", "-i", input, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams")
+func getVideoInfo(input string, logger *transcodingLogger) (info global.VideoInfo, err error) {
+	cmd := exec.Command("ffprobe", "-i", input, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", "-show_chapters")
+	stderr := logger.captureStderr(cmd)
+	start := time.Now()
 	out, err := utils.RunCmd(cmd)
+	logger.record(TRANSCODING_STAGE_PROBE, "", cmd, start, stderr.String(), err)
 	if err != nil {
 		return info, err
 	}
@@ -226,15 +421,18 @@ func getVideoInfo(input string) (info global.VideoInfo, err error) {
 	}
 
 	// 提取帧率信息
-	if len(info.Stream) > 0 && info.Stream[0].AvgFrameRate != "" {
+	for i := range info.Stream {
+		if info.Stream[i].CodecType != "video" || info.Stream[i].AvgFrameRate == "" {
+			continue
+		}
 		// 将帧率转换为浮点数（例如 "30000/1001" 转换为 29.97）
-		parts := strings.Split(info.Stream[0].AvgFrameRate, "/")
+		parts := strings.Split(info.Stream[i].AvgFrameRate, "/")
 		if len(parts) == 2 {
 			numerator, _ := strconv.Atoi(parts[0])
 			denominator, _ := strconv.Atoi(parts[1])
 			if denominator != 0 {
 				// 将浮点数转换为字符串
-				info.Stream[0].RFrameRate = fmt.Sprintf("%.2f", float64(numerator)/float64(denominator))
+				info.Stream[i].RFrameRate = fmt.Sprintf("%.2f", float64(numerator)/float64(denominator))
 			}
 		}
 	}
@@ -242,13 +440,21 @@ func getVideoInfo(input string) (info global.VideoInfo, err error) {
 	return info, nil
 }
 
-// CPU压缩视频
-func pressingVideo(inputFile, outputFile, quality, rate, fps string) error {
-	command := []string{"-i", inputFile, "-crf", "20", "-s", quality, "-b:v", rate,
-		"-c:v", "libx264", "-r", fps, "-c:a", "aac", "-f", "mpegts", outputFile,
+// 压缩视频并切片，所有目标共用一次解码
+func pressingVideo(ctx context.Context, transcodingInfo *dto.TranscodingInfo, targets []TranscodingTarget, fileNames []string, options transcodingOptions, onProgress func(string), logger *transcodingLogger) error {
+	// ffmpeg在输出目录下执行，使切片在m3u8中为相对路径
+	inputFile, err := filepath.Abs(transcodingInfo.InputFile)
+	if err != nil {
+		return err
 	}
 
-	_, err := utils.RunCmd(exec.Command("ffmpeg", command...))
+	cmd := exec.CommandContext(ctx, "ffmpeg", buildTranscodingCommand(inputFile, targets, fileNames, options)...)
+	cmd.Dir = transcodingInfo.OutputDir
+	utils.SetProcessGroup(cmd)
+	stderr := logger.captureStderr(cmd)
+	start := time.Now()
+	err = utils.RunCmdWithOutput(cmd, onProgress)
+	logger.record(TRANSCODING_STAGE_TRANSCODE, strings.Join(fileNames, ","), cmd, start, stderr.String(), err)
 	if err != nil {
 		utils.ErrorLog("压缩视频失败", "transcoding", err.Error())
 		return err
@@ -257,62 +463,182 @@ func pressingVideo(inputFile, outputFile, quality, rate, fps string) error {
 	return nil
 }
 
-// GPU压缩视频
-func pressingVideoGPU(inputFile, outputFile, quality, rate, fps string) error {
-	command := []string{"-i", inputFile, "-crf", "20", "-s", quality, "-preset", "p3", "-b:v", rate,
-		"-c:v", "h264_nvenc", "-r", fps, "-c:a", "aac", "-f", "mpegts", outputFile,
+// 生成转码命令，通过split将解码后的画面分发给各个目标
+func buildTranscodingCommand(inputFile string, targets []TranscodingTarget, fileNames []string, options transcodingOptions) []string {
+	filters := make([]string, 0, len(targets)+4)
+	video := "[0:" + strconv.Itoa(options.StreamIdx) + "]"
+	if len(options.Clips) > 0 {
+		// 先剪辑再处理画面，后续滤镜只处理保留的片段
+		trimFilters, output := clipFilters(video, options.Clips, false)
+		filters = append(filters, trimFilters...)
+		video = output
 	}
-
-	_, err := utils.RunCmd(exec.Command("ffmpeg", command...))
-	if err != nil {
-		utils.ErrorLog("压缩视频失败", "transcoding", err.Error())
-		return err
+	if options.ToneMap {
+		// HDR映射为SDR (BT.709)，需要ffmpeg支持zimg
+		filters = append(filters, video+"zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv[sdr]")
+		video = "[sdr]"
+	}
+	inputs := []string{"-i", inputFile}
+	if options.Watermark != nil {
+		// 图片水印作为第二个输入
+		if options.Watermark.Image != "" {
+			inputs = append(inputs, "-i", options.Watermark.Image)
+		}
+		watermarkFilters, output := options.Watermark.filters(video, "1:v")
+		filters = append(filters, watermarkFilters...)
+		video = output
+	}
+	split := video + "split=" + strconv.Itoa(len(targets))
+	for i := range targets {
+		split += fmt.Sprintf("[v%d]", i)
+	}
+	filters = append(filters, split)
+	command := make([]string, 0)
+	globalArgs := make(map[string]bool)
+	for i, t := range targets {
+		filters = append(filters, fmt.Sprintf("[v%d]scale=%s,%s[out%d]", i, strings.Replace(t.Resolution, "x", ":", 1), t.Encoder.Filter(), i))
+
+		// 硬件设备等全局参数只需添加一次
+		if args := t.Encoder.GlobalArgs(); len(args) > 0 && !globalArgs[strings.Join(args, " ")] {
+			globalArgs[strings.Join(args, " ")] = true
+			command = append(command, args...)
+		}
 	}
 
-	return nil
-}
-
-func generateVideoSlices(inputFile, outputDir, outputName string) (string, error) {
-	outputM3U8 := outputDir + outputName + ".m3u8"
-	outputTs := outputDir + outputName + "_%05d.ts"
+	// 音频，每个视频输出和纯音频流各一路，CMAF的视频输出不含音频，只使用纯音频流
+	cmaf := options.Packaging == global.PACKAGING_CMAF
+	audioMaps := make([]string, len(targets)+1)
+	if cmaf {
+		audioMaps = make([]string, 1)
+	}
+	for i := range audioMaps {
+		audioMaps[i] = "0:a:0"
+	}
+	if options.HasAudio && (options.AudioFilter != "" || len(options.Clips) > 0) {
+		audio := "[0:a:0]"
+		if len(options.Clips) > 0 {
+			trimFilters, output := clipFilters(audio, options.Clips, true)
+			filters = append(filters, trimFilters...)
+			audio = output
+		}
+		if options.AudioFilter != "" {
+			audio += options.AudioFilter + ","
+		}
+		audio += "asplit=" + strconv.Itoa(len(audioMaps))
+		for i := range audioMaps {
+			audioMaps[i] = fmt.Sprintf("[a%d]", i)
+			audio += audioMaps[i]
+		}
+		filters = append(filters, audio)
+	}
 
-	command := []string{"-i", inputFile, "-c", "copy",
-		"-map", "0", "-f", "segment", "-segment_list",
-		outputM3U8, "-segment_time", "10", outputTs,
+	command = append(command, inputs...)
+	command = append(command, "-filter_complex", strings.Join(filters, ";"),
+		"-progress", "pipe:1", "-nostats", "-y",
+	)
+	for i, t := range targets {
+		command = append(command, "-map", fmt.Sprintf("[out%d]", i))
+		command = append(command, t.Encoder.Args(t)...)
+		command = append(command, "-r", t.FPS,
+			// 固定关键帧间隔，保证各分辨率的切片边界对齐
+			"-force_key_frames", "expr:gte(t,n_forced*"+strconv.Itoa(HLS_SEGMENT_TIME)+")",
+		)
+		if options.HasAudio && !cmaf {
+			command = append(command, "-map", audioMaps[i], "-c:a", "aac", "-b:a", t.AudioBitrate)
+		}
+		fmp4 := cmaf || requireFmp4(t.Encoder)
+		command = append(command, hlsOutputArgs(fileNames[i], fmp4, options.KeyInfoFile)...)
 	}
 
-	_, err := utils.RunCmd(exec.Command("ffmpeg", command...))
-	if err != nil {
-		utils.ErrorLog("生成视频切片失败", "transcoding", err.Error())
-		return outputM3U8, err
+	// 纯音频流，用于后台播放，CMAF时作为所有视频流共用的音频轨道
+	if options.HasAudio {
+		command = append(command, "-map", audioMaps[len(audioMaps)-1], "-vn", "-c:a", "aac", "-b:a", AUDIO_RENDITION_BITRATE)
+		command = append(command, hlsOutputArgs(AUDIO_QUALITY, cmaf, options.KeyInfoFile)...)
 	}
 
-	return outputM3U8, nil
+	return command
 }
 
-// 保存m3u8文件
-func saveM3u8File(transcodingInfo *dto.TranscodingInfo, fileName, m3u8File string) error {
-	file, err := os.Open(m3u8File)
-	if err != nil {
-		utils.ErrorLog("打开m3u8文件失败", "transcoding", err.Error())
-		return err
+// HLS输出参数
+func hlsOutputArgs(name string, fmp4 bool, keyInfoFile string) []string {
+	args := []string{"-f", "hls", "-hls_time", strconv.Itoa(HLS_SEGMENT_TIME), "-hls_playlist_type", "vod"}
+	if keyInfoFile != "" {
+		args = append(args, "-hls_key_info_file", keyInfoFile)
+	}
+	if fmp4 {
+		return append(args, "-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", name+"_init.mp4",
+			"-hls_segment_filename", name+"_%05d.m4s", name+".m3u8",
+		)
 	}
 
-	bytes, err := io.ReadAll(file)
+	return append(args, "-hls_segment_filename", name+"_%05d.ts", name+".m3u8")
+}
+
+// 读取m3u8文件
+func readM3u8File(transcodingInfo *dto.TranscodingInfo, fileName string) (model.VideoIndexFile, error) {
+	bytes, err := os.ReadFile(transcodingInfo.OutputDir + fileName + ".m3u8")
 	if err != nil {
 		utils.ErrorLog("读取m3u8文件失败", "transcoding", err.Error())
-		return err
+		return model.VideoIndexFile{}, err
 	}
 
-	file.Close()
-
-	global.Mysql.Create(&model.VideoIndexFile{
+	indexFile := model.VideoIndexFile{
 		ResourceID: transcodingInfo.ResourceID,
 		Quality:    fileName,
 		DirName:    transcodingInfo.DirName,
 		Content:    string(bytes),
-	})
+	}
+	fillVariantInfo(&indexFile, transcodingInfo.OutputDir)
+	indexFile.StartPts = getPlaylistStartPts(transcodingInfo.OutputDir + fileName + ".m3u8")
+
+	return indexFile, nil
+}
+
+// 获取播放列表中首个切片的起始时间戳(90kHz)，用于字幕的X-TIMESTAMP-MAP
+// 通过播放列表读取，加密的切片使用本地的密钥文件解密
+func getPlaylistStartPts(m3u8File string) *int {
+	cmd := exec.Command("ffprobe", "-v", "error", "-allowed_extensions", "ALL", "-protocol_whitelist", "file,crypto",
+		"-i", m3u8File, "-print_format", "json", "-show_format")
+	out, err := utils.RunCmd(cmd)
+	if err != nil {
+		utils.ErrorLog("读取切片起始时间失败", "transcoding", err.Error())
+		return nil
+	}
 
+	var info global.VideoInfo
+	if err := json.Unmarshal(out.Bytes(), &info); err != nil {
+		return nil
+	}
+	startTime, err := strconv.ParseFloat(info.Format.StartTime, 64)
+	if err != nil {
+		return nil
+	}
+
+	pts := int(math.Round(startTime * 90000))
+	return &pts
+}
+
+// 保存转码结果，替换资源原有的索引文件、密钥及缩略图，需要在事务中调用
+func saveTranscodingResult(tx *gorm.DB, resourceId uint, result *transcodingResult) error {
+	if err := tx.Where("resource_id = ?", resourceId).Delete(&model.VideoIndexFile{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&result.IndexFiles).Error; err != nil {
+		return err
+	}
+	if result.VideoKey != nil {
+		if err := saveVideoKey(tx, result.VideoKey); err != nil {
+			return err
+		}
+	}
+
+	// 缩略图生成失败时同样删除原有缩略图，原目录会被清理
+	if err := tx.Unscoped().Where("resource_id = ?", resourceId).Delete(&model.Storyboard{}).Error; err != nil {
+		return err
+	}
+	if result.Storyboard != nil {
+		return tx.Create(result.Storyboard).Error
+	}
 	return nil
 }
 
@@ -335,6 +661,9 @@ func completeTransCoding(videoId, resourceId uint, status int) error {
 		return err
 	}
 
+	// 推送转码结果
+	finishTranscodingProgress(videoId, resourceId, status)
+
 	// 获取转码中资源的数量
 	var count int64
 	global.Mysql.Model(&model.Resource{}).Where("vid = ? and status = ?", videoId, global.VIDEO_PROCESSING).Count(&count)
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"os/exec"
+	"strings"
+	"time"
+
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	TRANSCODING_LOG_STDERR_SIZE = 4096                             // 保存的错误输出长度（字节）
+	TRANSCODING_LOG_RETENTION   = time.Hour * time.Duration(24*30) // 转码日志保留时间
+)
+
+// 转码执行阶段
+const (
+	TRANSCODING_STAGE_PROBE      = "probe"      // 读取视频信息
+	TRANSCODING_STAGE_DECODE     = "decode"     // 校验视频流
+	TRANSCODING_STAGE_LOUDNORM   = "loudnorm"   // 测量响度
+	TRANSCODING_STAGE_TRANSCODE  = "transcode"  // 转码切片
+	TRANSCODING_STAGE_STORYBOARD = "storyboard" // 生成缩略图
+)
+
+// 转码日志记录器，为nil时不记录
+type transcodingLogger struct {
+	ResourceID uint
+	TaskID     uint
+}
+
+// 记录命令的错误输出末尾，需要在命令执行前调用
+func (l *transcodingLogger) captureStderr(cmd *exec.Cmd) *tailBuffer {
+	if l == nil {
+		return nil
+	}
+
+	stderr := &tailBuffer{size: TRANSCODING_LOG_STDERR_SIZE}
+	cmd.Stderr = stderr
+	return stderr
+}
+
+// 记录一次命令的执行情况，成功时也保存错误输出的末尾，便于排查警告和编码参数
+func (l *transcodingLogger) record(stage, target string, cmd *exec.Cmd, start time.Time, stderr string, err error) {
+	if l == nil {
+		return
+	}
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	// 命令未能启动时没有错误输出
+	if stderr == "" && err != nil {
+		stderr = err.Error()
+	}
+	stderr = tailString(stderr, TRANSCODING_LOG_STDERR_SIZE)
+
+	log := model.TranscodingLog{
+		ResourceID: l.ResourceID,
+		TaskID:     l.TaskID,
+		Stage:      stage,
+		Target:     target,
+		Command:    strings.Join(cmd.Args, " "),
+		Duration:   time.Since(start).Milliseconds(),
+		ExitCode:   exitCode,
+		Stderr:     stderr,
+	}
+	if err := global.Mysql.Create(&log).Error; err != nil {
+		utils.ErrorLog("保存转码日志失败", "transcoding", err.Error())
+	}
+}
+
+// 获取转码任务及日志
+func GetTranscodingLogList(resourceId uint) (tasks []vo.TranscodingTaskResp, logs []vo.TranscodingLogResp) {
+	global.Mysql.Model(&model.TranscodingTask{}).Where("resource_id = ?", resourceId).Order("id desc").Scan(&tasks)
+	global.Mysql.Model(&model.TranscodingLog{}).Where("resource_id = ?", resourceId).Order("id desc").Scan(&logs)
+
+	return
+}
+
+// 清理过期的转码日志
+func CleanTranscodingLogs() {
+	if err := global.Mysql.Unscoped().Where("created_at < ?", time.Now().Add(-TRANSCODING_LOG_RETENTION)).
+		Delete(&model.TranscodingLog{}).Error; err != nil {
+		utils.ErrorLog("清理转码日志失败", "transcoding", err.Error())
+	}
+}
+
+// 只保留末尾部分的输出缓冲区
+type tailBuffer struct {
+	size int
+	buf  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	// 超出两倍长度时再截断，减少复制
+	if len(b.buf) > 2*b.size {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.size:]...)
+	}
+
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	if b == nil {
+		return ""
+	}
+
+	tail := b.buf
+	if len(tail) > b.size {
+		tail = tail[len(tail)-b.size:]
+	}
+
+	// 截断位置可能在多字节字符中间
+	return strings.ToValidUTF8(string(tail), "")
+}
+
+// 截取字符串末尾
+func tailString(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+
+	// 截断位置可能在多字节字符中间
+	return strings.ToValidUTF8(s[len(s)-size:], "")
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		writes []string
+		want   string
+	}{
+		{"未超出长度", 10, []string{"abc", "def"}, "abcdef"},
+		{"超出长度", 4, []string{"abc", "def"}, "cdef"},
+		{"多次截断", 3, []string{"abcdefg", "hi", "jklmnop"}, "nop"},
+		{"不截断多字节字符", 7, []string{"转码失败"}, "失败"},
+		{"大量输出", 5, []string{strings.Repeat("x", 100), "12345"}, "12345"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := &tailBuffer{size: tt.size}
+			for _, w := range tt.writes {
+				buffer.Write([]byte(w))
+			}
+			if got := buffer.String(); got != tt.want {
+				t.Errorf("tailBuffer.String() = %q, want %q", got, tt.want)
+			}
+			if len(buffer.buf) > 2*tt.size {
+				t.Errorf("tailBuffer keeps %d bytes, limit %d", len(buffer.buf), 2*tt.size)
+			}
+		})
+	}
+
+	var buffer *tailBuffer
+	if got := buffer.String(); got != "" {
+		t.Errorf("nil tailBuffer.String() = %q", got)
+	}
+}
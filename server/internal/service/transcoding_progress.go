@@ -0,0 +1,179 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/pkg/ws"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 进度上报的最小间隔
+const TRANSCODING_PROGRESS_INTERVAL = time.Second
+
+var (
+	transcodingClient  = make(map[interface{}]map[interface{}]*websocket.Conn)  // websocket客户端链接池
+	transcodingChannel = make(map[interface{}]map[interface{}]chan interface{}) // 消息通道
+	transcodingMux     sync.Mutex                                               // 互斥锁
+)
+
+// 创建ffmpeg进度解析函数，用于 -progress pipe:1 的输出，所有目标共用同一进度
+func newProgressReporter(videoId, resourceId uint, targets []string, duration float64) func(line string) {
+	start := time.Now()
+	var lastReport time.Time
+	return func(line string) {
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return
+		}
+
+		var percent float64
+		switch key {
+		case "out_time_us", "out_time_ms": // 两者的单位均为微秒
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || duration <= 0 {
+				return
+			}
+			percent = float64(us) / 1e6 / duration * 100
+			if percent > 99.9 {
+				percent = 99.9
+			}
+			if time.Since(lastReport) < TRANSCODING_PROGRESS_INTERVAL {
+				return
+			}
+		case "progress":
+			if value != "end" {
+				return
+			}
+			percent = 100
+		default:
+			return
+		}
+
+		lastReport = time.Now()
+		eta := 0
+		if percent > 0 && percent < 100 {
+			elapsed := time.Since(start).Seconds()
+			eta = int(elapsed * (100 - percent) / percent)
+		}
+
+		progress := make([]vo.TranscodingProgressResp, 0, len(targets))
+		for _, target := range targets {
+			progress = append(progress, vo.TranscodingProgressResp{
+				Target:  target,
+				Percent: float64(int(percent*10)) / 10,
+				Eta:     eta,
+			})
+		}
+		reportTranscodingProgress(videoId, resourceId, progress)
+	}
+}
+
+// 保存并推送转码进度
+func reportTranscodingProgress(videoId, resourceId uint, progress []vo.TranscodingProgressResp) {
+	for _, p := range progress {
+		cache.SetTranscodingProgress(resourceId, p)
+	}
+	cache.PublishTranscodingMessage(vo.TranscodingProgressMsg{
+		VideoID:    videoId,
+		ResourceID: resourceId,
+		Status:     global.VIDEO_PROCESSING,
+		Progress:   cache.GetTranscodingProgress(resourceId),
+	})
+}
+
+// 推送转码结束状态并清理进度
+func finishTranscodingProgress(videoId, resourceId uint, status int) {
+	cache.DelTranscodingProgress(resourceId)
+	cache.PublishTranscodingMessage(vo.TranscodingProgressMsg{
+		VideoID:    videoId,
+		ResourceID: resourceId,
+		Status:     status,
+		Progress:   []vo.TranscodingProgressResp{},
+	})
+}
+
+// 订阅转码进度，转发给本节点的客户端，转码可能在独立的工作进程中执行
+func InitTranscodingMessage() {
+	go cache.SubscribeTranscodingMessage(func(msg vo.TranscodingProgressMsg) {
+		setTranscodingMessage(msg.VideoID, &msg)
+	})
+}
+
+// 处理转码进度ws请求
+func GetTranscodingProgressConnect(ctx *gin.Context, videoId uint) {
+	userId := ctx.GetUint("userId")
+	var video model.Video
+	global.Mysql.Model(&model.Video{}).Select("id").Where("id = ? and uid = ?", videoId, userId).First(&video)
+	if video.ID == 0 {
+		return
+	}
+
+	conn, err := ws.CreateWsConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		utils.ErrorLog("升级websocket失败", "transcoding", err.Error())
+		return
+	}
+
+	clientId := uuid.New().String()
+	m := make(chan interface{})
+	addTranscodingClient(clientId, videoId, conn, m)
+
+	// 设置客户端关闭ws链接回调函数
+	conn.SetCloseHandler(func(code int, text string) error {
+		deleteTranscodingClient(clientId, videoId)
+		return nil
+	})
+
+	ws.WsHandler(conn, clientId, videoId, m, deleteTranscodingClient)
+}
+
+func addTranscodingClient(id, groupId interface{}, conn *websocket.Conn, m chan interface{}) {
+	transcodingMux.Lock()
+	if transcodingClient[groupId] == nil {
+		transcodingClient[groupId] = make(map[interface{}]*websocket.Conn)
+		transcodingChannel[groupId] = make(map[interface{}]chan interface{})
+	}
+	transcodingClient[groupId][id] = conn
+	transcodingChannel[groupId][id] = m
+	transcodingMux.Unlock()
+}
+
+// 移除客户端和管道
+func deleteTranscodingClient(id, groupId interface{}) {
+	transcodingMux.Lock()
+	delete(transcodingClient[groupId], id)
+	delete(transcodingChannel[groupId], id)
+	if len(transcodingClient[groupId]) == 0 {
+		delete(transcodingClient, groupId)
+		delete(transcodingChannel, groupId)
+	}
+	transcodingMux.Unlock()
+}
+
+// 推送消息到视频的所有客户端
+func setTranscodingMessage(groupId, content interface{}) {
+	transcodingMux.Lock()
+	all := make([]chan interface{}, 0, len(transcodingChannel[groupId]))
+	for _, m := range transcodingChannel[groupId] {
+		all = append(all, m)
+	}
+	transcodingMux.Unlock()
+	go func() {
+		for _, m := range all {
+			select {
+			case m <- content:
+			case <-time.After(TRANSCODING_PROGRESS_INTERVAL):
+			}
+		}
+	}()
+}
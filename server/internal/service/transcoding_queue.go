@@ -0,0 +1,463 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+// 空闲时轮询任务表的间隔
+const TRANSCODING_POLL_INTERVAL = time.Second * time.Duration(5)
+
+// 重试退避的基础时间，第n次重试等待 base * 2^(n-1)
+const TRANSCODING_RETRY_BACKOFF = time.Second * time.Duration(30)
+
+// 执行中的任务超过该时间没有心跳时视为中断，重新排队
+const TRANSCODING_HEARTBEAT_TIMEOUT = time.Minute * time.Duration(1)
+
+// 提交时任务已不在执行中，通常是被取消
+var errTranscodingTaskCancelled = errors.New("转码任务已取消")
+
+// 执行中任务的取消函数，任务ID -> context.CancelFunc
+var transcodingCancels sync.Map
+
+// 当前工作进程名称，用于后台查看任务由哪个节点执行
+var transcodingWorkerName = getTranscodingWorkerName()
+
+// 工作协程的停止信号，数量即当前工作协程数量，队列未启动时为nil
+var (
+	transcodingWorkerStops []chan struct{}
+	transcodingWorkerMutex sync.Mutex
+)
+
+// 重新排队中断任务的定时协程只启动一次
+var transcodingRequeueOnce sync.Once
+
+// 初始化转码队列，可在API服务中运行，也可由独立的worker进程运行
+func InitTranscodingQueue() {
+	recoverTranscodingTask()
+	transcodingRequeueOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(TRANSCODING_HEARTBEAT_TIMEOUT)
+			defer ticker.Stop()
+			for range ticker.C {
+				requeueStaleTranscodingTask()
+			}
+		}()
+	})
+
+	transcodingWorkerMutex.Lock()
+	transcodingWorkerStops = make([]chan struct{}, 0)
+	transcodingWorkerMutex.Unlock()
+	ResizeTranscodingWorkers(global.Config.Transcoding.WorkerCount)
+}
+
+// 启动或停止API服务中的转码队列，停止时工作协程在当前任务完成后退出
+func SetEmbeddedTranscodingWorker(enabled bool) {
+	transcodingWorkerMutex.Lock()
+	running := transcodingWorkerStops != nil
+	if running && !enabled {
+		for _, stop := range transcodingWorkerStops {
+			close(stop)
+		}
+		transcodingWorkerStops = nil
+		zap.L().Info("内置转码队列已停止", zap.String("module", "transcoding"))
+	}
+	transcodingWorkerMutex.Unlock()
+
+	if !running && enabled {
+		InitTranscodingQueue()
+	}
+}
+
+// 调整当前进程的工作协程数量，减少时协程在当前任务完成后退出
+func ResizeTranscodingWorkers(workerCount int) {
+	if workerCount <= 0 {
+		workerCount = 1
+	}
+
+	transcodingWorkerMutex.Lock()
+	defer transcodingWorkerMutex.Unlock()
+	// 转码队列未在当前进程中运行
+	if transcodingWorkerStops == nil || len(transcodingWorkerStops) == workerCount {
+		return
+	}
+
+	for len(transcodingWorkerStops) < workerCount {
+		stop := make(chan struct{})
+		transcodingWorkerStops = append(transcodingWorkerStops, stop)
+		go transcodingWorker(stop)
+	}
+	for len(transcodingWorkerStops) > workerCount {
+		close(transcodingWorkerStops[len(transcodingWorkerStops)-1])
+		transcodingWorkerStops = transcodingWorkerStops[:len(transcodingWorkerStops)-1]
+	}
+
+	zap.L().Info("转码工作协程数量:"+strconv.Itoa(workerCount), zap.String("module", "transcoding"))
+}
+
+// 添加转码任务，源文件由上传协程上传到OSS，不阻塞请求
+func AddTranscodingTask(vid, resourceId uint, dirName string) error {
+	task := model.TranscodingTask{
+		Vid:        vid,
+		ResourceID: resourceId,
+		DirName:    dirName,
+	}
+	// 源目录已被其他资源使用时输出到新目录，避免覆盖其他资源的切片
+	if isVideoDirReferenced(dirName) {
+		task.OutputDirName = generateVideoFilename()
+	}
+
+	return createTranscodingTask(&task)
+}
+
+// 恢复服务重启时被中断的任务
+func recoverTranscodingTask() {
+	requeueStaleTranscodingTask()
+
+	// 没有转码任务的处理中资源无法恢复，标记为处理失败
+	var resources []model.Resource
+	global.Mysql.Model(&model.Resource{}).Where("status = ? and id not in (?)", global.VIDEO_PROCESSING,
+		global.Mysql.Model(&model.TranscodingTask{}).Select("resource_id")).Find(&resources)
+	for _, r := range resources {
+		utils.ErrorLog("转码资源无法恢复", "transcoding", utils.UintToString(r.ID))
+		completeTransCoding(r.Vid, r.ID, global.PROCESSING_FAIL)
+	}
+}
+
+// 心跳超时的执行中任务重新排队，工作进程可能已退出
+func requeueStaleTranscodingTask() {
+	result := global.Mysql.Model(&model.TranscodingTask{}).
+		Where("status = ? and (heartbeat_at is null or heartbeat_at < ?)", global.TRANSCODING_RUNNING, time.Now().Add(-TRANSCODING_HEARTBEAT_TIMEOUT)).
+		Updates(map[string]interface{}{
+			"status":      global.TRANSCODING_QUEUED,
+			"next_run_at": time.Now(),
+		})
+	if result.Error != nil {
+		utils.ErrorLog("恢复转码任务失败", "transcoding", result.Error.Error())
+		return
+	}
+	if result.RowsAffected > 0 {
+		zap.L().Info("重新排队被中断的转码任务:"+strconv.FormatInt(result.RowsAffected, 10), zap.String("module", "transcoding"))
+	}
+}
+
+// 通知空闲的工作进程有新任务
+func notifyTranscodingWorker(taskId uint) {
+	cache.PushTranscodingTask(taskId)
+}
+
+func transcodingWorker(stop chan struct{}) {
+	for {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			task, ok := claimTranscodingTask()
+			if !ok {
+				break
+			}
+			runTranscodingTask(task)
+		}
+
+		// 等待新任务通知，超时后重新检查任务表，处理重试及丢失的通知
+		cache.WaitTranscodingTask(TRANSCODING_POLL_INTERVAL)
+	}
+}
+
+// 领取一个到期的排队任务
+func claimTranscodingTask() (model.TranscodingTask, bool) {
+	var tasks []model.TranscodingTask
+	global.Mysql.Where("status = ? and next_run_at <= ?", global.TRANSCODING_QUEUED, time.Now()).
+		Order("id").Limit(10).Find(&tasks)
+
+	for _, task := range tasks {
+		// 通过状态条件更新保证同一任务只会被领取一次
+		result := global.Mysql.Model(&model.TranscodingTask{}).
+			Where("id = ? and status = ?", task.ID, global.TRANSCODING_QUEUED).
+			Updates(map[string]interface{}{
+				"status":       global.TRANSCODING_RUNNING,
+				"worker":       transcodingWorkerName,
+				"heartbeat_at": time.Now(),
+			})
+		if result.Error == nil && result.RowsAffected == 1 {
+			task.Status = global.TRANSCODING_RUNNING
+			return task, true
+		}
+	}
+
+	return model.TranscodingTask{}, false
+}
+
+// 获取工作进程名称
+func getTranscodingWorkerName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return hostname + ":" + strconv.Itoa(os.Getpid())
+}
+
+// 执行转码任务
+func runTranscodingTask(task model.TranscodingTask) {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	transcodingCancels.Store(task.ID, cancel)
+	defer transcodingCancels.Delete(task.ID)
+	go watchTranscodingCancel(ctx, task.ID, cancel)
+
+	// 返回nil时任务已提交，之后的取消不再生效
+	err := executeTranscodingTask(ctx, task)
+	if err == nil {
+		// 重新转码不改变资源状态
+		if task.Retranscode {
+			cache.DelTranscodingProgress(task.ResourceID)
+		} else {
+			completeTransCoding(task.Vid, task.ResourceID, global.WAITING_REVIEW)
+		}
+		zap.L().Info("转码完成，资源ID:"+utils.UintToString(task.ResourceID)+"，耗时:"+time.Since(start).String(),
+			zap.String("module", "transcoding"))
+		return
+	}
+
+	if ctx.Err() != nil || errors.Is(err, errTranscodingTaskCancelled) {
+		// 任务被取消，删除已生成的文件
+		removeTaskOutput(task)
+		cache.DelTranscodingProgress(task.ResourceID)
+		zap.L().Info("转码已取消，资源ID:"+utils.UintToString(task.ResourceID), zap.String("module", "transcoding"))
+		return
+	}
+
+	utils.ErrorLog("转码任务失败", "transcoding", err.Error())
+	retries := task.Retries + 1
+	if retries > global.Config.Transcoding.MaxRetry {
+		if !updateRunningTask(task.ID, map[string]interface{}{
+			"status": global.TRANSCODING_FAILED,
+			"error":  err.Error(),
+		}) {
+			return
+		}
+		// 输出到新目录的任务失败时保留原有切片，只删除新目录
+		if task.OutputDirName != "" && task.OutputDirName != task.DirName {
+			removeTaskOutput(task)
+		}
+		if task.Retranscode {
+			cache.DelTranscodingProgress(task.ResourceID)
+		} else {
+			completeTransCoding(task.Vid, task.ResourceID, global.PROCESSING_FAIL)
+		}
+		return
+	}
+
+	// 指数退避后重新排队
+	backoff := TRANSCODING_RETRY_BACKOFF * time.Duration(1<<(retries-1))
+	updateRunningTask(task.ID, map[string]interface{}{
+		"status":      global.TRANSCODING_QUEUED,
+		"retries":     retries,
+		"next_run_at": time.Now().Add(backoff),
+		"error":       err.Error(),
+	})
+}
+
+// 更新执行中的任务，任务已被取消时返回false
+func updateRunningTask(taskId uint, values map[string]interface{}) bool {
+	result := global.Mysql.Model(&model.TranscodingTask{}).
+		Where("id = ? and status = ?", taskId, global.TRANSCODING_RUNNING).Updates(values)
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// 定时更新心跳并检查任务状态，任务在其他进程中被取消时结束转码
+func watchTranscodingCancel(ctx context.Context, taskId uint, cancel context.CancelFunc) {
+	ticker := time.NewTicker(TRANSCODING_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var task model.TranscodingTask
+			global.Mysql.Select("status").Where("id = ?", taskId).Find(&task)
+			if task.Status == global.TRANSCODING_CANCELLED {
+				cancel()
+				return
+			}
+			global.Mysql.Model(&model.TranscodingTask{}).Where("id = ? and status = ?", taskId, global.TRANSCODING_RUNNING).
+				Update("heartbeat_at", time.Now())
+		}
+	}
+}
+
+// 取消资源的转码任务
+func CancelResourceTranscoding(resourceId uint) {
+	cancelTranscodingTasks(global.Mysql.Where("resource_id = ?", resourceId))
+}
+
+// 取消视频下所有资源的转码任务
+func CancelVideoTranscoding(videoId uint) {
+	cancelTranscodingTasks(global.Mysql.Where("vid = ?", videoId))
+}
+
+func cancelTranscodingTasks(query *gorm.DB) {
+	var tasks []model.TranscodingTask
+	query.Where("status in ?", []int{global.TRANSCODING_UPLOADING, global.TRANSCODING_QUEUED, global.TRANSCODING_RUNNING}).Find(&tasks)
+	for _, task := range tasks {
+		result := global.Mysql.Model(&model.TranscodingTask{}).
+			Where("id = ? and status = ?", task.ID, task.Status).Update("status", global.TRANSCODING_CANCELLED)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if task.Status != global.TRANSCODING_RUNNING {
+			// 排队中的任务可能有上次失败残留的文件
+			removeTaskOutput(task)
+			continue
+		}
+
+		// 执行中的任务结束ffmpeg进程，文件由工作协程清理
+		if cancel, ok := transcodingCancels.Load(task.ID); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+}
+
+// 删除任务的转码输出，源目录保留上传的视频文件
+func removeTaskOutput(task model.TranscodingTask) {
+	outputDirName := task.DirName
+	if task.OutputDirName != "" {
+		outputDirName = task.OutputDirName
+	}
+	removeTranscodingFiles(outputDirName, outputDirName == task.DirName)
+}
+
+func executeTranscodingTask(ctx context.Context, task model.TranscodingTask) error {
+	// 源文件不在本地时从OSS下载
+	if err := fetchSourceVideo(task.DirName); err != nil {
+		return err
+	}
+
+	inputFile := "./upload/video/" + task.DirName + "/upload.mp4"
+	logger := &transcodingLogger{ResourceID: task.ResourceID, TaskID: task.ID}
+	transcodingInfo, err := probeVideoInfo(inputFile, logger)
+	if err != nil {
+		return err
+	}
+
+	// 重新转码及剪辑时输出到新目录
+	outputDirName := task.DirName
+	if task.OutputDirName != "" {
+		outputDirName = task.OutputDirName
+	}
+	outputDir := "./upload/video/" + outputDirName + "/"
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// 清理上一次执行残留的进度，索引文件在保存时替换
+	cache.DelTranscodingProgress(task.ResourceID)
+	replacedDirName := getIndexDirName(task.ResourceID)
+
+	// 上传者选择添加水印时，获取用户名用于文字水印
+	var resource model.Resource
+	global.Mysql.Where("id = ?", task.ResourceID).First(&resource)
+	if resource.Watermark {
+		transcodingInfo.Watermark = true
+		if user, err := FindUserById(resource.Uid); err == nil {
+			transcodingInfo.Uploader = user.Username
+		}
+	}
+
+	transcodingInfo.VideoID = task.Vid
+	transcodingInfo.ResourceID = task.ResourceID
+	transcodingInfo.DirName = outputDirName
+	transcodingInfo.OutputDir = outputDir
+	transcodingInfo.InputFile = inputFile
+
+	// 只转码剪辑保留的片段，剪辑任务使用任务中的片段，提交后才写入资源
+	var clip *clipResult
+	if task.Clip {
+		if clip, err = getClipResult(task.Clips, transcodingInfo.Duration); err != nil {
+			return err
+		}
+		transcodingInfo.Clips = clip.Ranges
+		transcodingInfo.Duration = clip.Duration
+	} else if transcodingInfo.Clips = parseClips(resource.Clips); len(transcodingInfo.Clips) > 0 {
+		transcodingInfo.Duration = getClipDuration(transcodingInfo.Clips)
+	}
+
+	result, err := VideoTransCoding(ctx, transcodingInfo, logger)
+	if err != nil {
+		return err
+	}
+	result.Clip = clip
+	if err := commitTranscodingTask(ctx, task, result, replacedDirName); err != nil {
+		return err
+	}
+
+	if clip != nil {
+		applyClipResult(resource, clip)
+		return nil
+	}
+	// 导入视频文件中的章节
+	if !task.Retranscode {
+		importTranscodingChapters(transcodingInfo)
+	}
+	// 已上传的字幕按新切片的起始时间戳重新切片
+	regenerateSubtitles(resource, nil)
+
+	return nil
+}
+
+// 提交转码任务，在同一事务中替换索引文件并将任务标记为完成
+// 任务只有仍在执行中时才能提交，提交后取消不再生效
+func commitTranscodingTask(ctx context.Context, task model.TranscodingTask, result *transcodingResult, replacedDirName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	values := map[string]interface{}{
+		"status": global.TRANSCODING_DONE,
+		"error":  "",
+	}
+	// 记录被替换的切片目录，等待正在播放的客户端结束后清理
+	if replacedDirName != "" && replacedDirName != result.IndexFiles[0].DirName {
+		values["replaced_dir_name"] = replacedDirName
+	}
+
+	return global.Mysql.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&model.TranscodingTask{}).
+			Where("id = ? and status = ?", task.ID, global.TRANSCODING_RUNNING).Updates(values)
+		if update.Error != nil {
+			utils.ErrorLog("提交转码任务失败", "transcoding", update.Error.Error())
+			return update.Error
+		}
+		if update.RowsAffected != 1 {
+			return errTranscodingTaskCancelled
+		}
+
+		if err := saveTranscodingResult(tx, task.ResourceID, result); err != nil {
+			utils.ErrorLog("保存转码结果失败", "transcoding", err.Error())
+			return err
+		}
+		if result.Clip != nil {
+			return tx.Model(&model.Resource{}).Where("id = ?", task.ResourceID).Updates(map[string]interface{}{
+				"clips":    result.Clip.Clips,
+				"duration": result.Clip.Duration,
+			}).Error
+		}
+		return nil
+	})
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	TUS_VERSION             = "1.0.0"
+	TUS_EXTENSIONS          = "creation,termination,checksum"
+	TUS_CHECKSUM_ALGORITHMS = "md5,sha1,sha256"
+	TUS_PART_FILE           = "upload.part" // 上传中的文件，完成后重命名为upload.mp4
+)
+
+// 上传中的文件锁，避免同一文件被并发写入
+var tusLocks sync.Map
+
+// tus协议错误，包含HTTP状态码
+type TusError struct {
+	Status int
+	Msg    string
+}
+
+func (e *TusError) Error() string {
+	return e.Msg
+}
+
+// 上传进度
+type TusUploadInfo struct {
+	ID     string
+	Offset int64
+	Length int64
+}
+
+// 创建上传，Upload-Metadata中的filename为文件名，hash为文件哈希，没有哈希时使用上传ID
+func TusCreateUpload(ctx *gin.Context, length int64, metadata string) (TusUploadInfo, error) {
+	userId := ctx.GetUint("userId")
+	if length <= 0 {
+		return TusUploadInfo{}, &TusError{http.StatusBadRequest, "文件大小有误"}
+	}
+	if !utils.FileSize(length, 1, global.Config.File.MaxVideoSize) {
+		return TusUploadInfo{}, &TusError{http.StatusRequestEntityTooLarge, "文件大小超出限制"}
+	}
+
+	meta := parseTusMetadata(metadata)
+	fileName := meta["filename"]
+	if !utils.IsVideoType(path.Ext(fileName)) {
+		return TusUploadInfo{}, &TusError{http.StatusBadRequest, "文件类型错误"}
+	}
+
+	if utf8.RuneCountInString(fileName) > 100 || len(meta["hash"]) > 64 {
+		return TusUploadInfo{}, &TusError{http.StatusBadRequest, "文件信息有误"}
+	}
+
+	// 相同文件重新创建时继续之前的上传
+	fileHash := meta["hash"]
+	if fileHash != "" {
+		var fileInfo model.VideoFile
+		global.Mysql.Where("uid = ? and hash = ?", userId, fileHash).Limit(1).Find(&fileInfo)
+		if fileInfo.ID != 0 {
+			if fileInfo.Size != length && !isTusUploadCompleted(fileInfo.DirName) {
+				os.Remove("./upload/video/" + fileInfo.DirName + "/" + TUS_PART_FILE)
+				global.Mysql.Model(&model.VideoFile{}).Where("id = ?", fileInfo.ID).Update("size", length)
+				fileInfo.Size = length
+			}
+			return getTusUploadInfo(fileInfo), nil
+		}
+	}
+
+	dirName := generateVideoFilename()
+	if fileHash == "" {
+		fileHash = dirName
+	}
+	if err := os.MkdirAll("./upload/video/"+dirName, os.ModePerm); err != nil {
+		utils.ErrorLog("创建上传目录失败", "upload", err.Error())
+		return TusUploadInfo{}, &TusError{http.StatusInternalServerError, "创建上传失败"}
+	}
+
+	fileInfo := model.VideoFile{Uid: userId, Hash: fileHash, DirName: dirName, OriginalName: fileName, Size: length}
+	if err := global.Mysql.Create(&fileInfo).Error; err != nil {
+		utils.ErrorLog("保存视频文件信息失败", "upload", err.Error())
+		return TusUploadInfo{}, &TusError{http.StatusInternalServerError, "创建上传失败"}
+	}
+
+	return TusUploadInfo{ID: dirName, Length: length}, nil
+}
+
+// 获取上传进度
+func TusGetUpload(ctx *gin.Context, id string) (TusUploadInfo, error) {
+	fileInfo, err := findTusUpload(ctx, id)
+	if err != nil {
+		return TusUploadInfo{}, err
+	}
+
+	return getTusUploadInfo(fileInfo), nil
+}
+
+// 从offset处追加数据，checksum为Upload-Checksum，校验失败时丢弃本次数据
+func TusPatchUpload(ctx *gin.Context, id string, offset int64, checksum string, body io.Reader) (TusUploadInfo, error) {
+	fileInfo, err := findTusUpload(ctx, id)
+	if err != nil {
+		return TusUploadInfo{}, err
+	}
+
+	lock, _ := tusLocks.LoadOrStore(id, &sync.Mutex{})
+	if !lock.(*sync.Mutex).TryLock() {
+		return TusUploadInfo{}, &TusError{http.StatusLocked, "文件正在上传"}
+	}
+	defer lock.(*sync.Mutex).Unlock()
+
+	info := getTusUploadInfo(fileInfo)
+	if info.Offset != offset {
+		return info, &TusError{http.StatusConflict, "上传位置不一致"}
+	}
+	if info.Offset >= info.Length {
+		return info, nil
+	}
+
+	var checksumHash hash.Hash
+	var expected []byte
+	if checksum != "" {
+		algorithm, value, _ := strings.Cut(checksum, " ")
+		if checksumHash = newTusChecksumHash(algorithm); checksumHash == nil {
+			return info, &TusError{http.StatusBadRequest, "不支持的校验算法"}
+		}
+		if expected, err = base64.StdEncoding.DecodeString(value); err != nil {
+			return info, &TusError{http.StatusBadRequest, "校验值有误"}
+		}
+	}
+
+	filePath := "./upload/video/" + fileInfo.DirName + "/" + TUS_PART_FILE
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		utils.ErrorLog("打开上传文件失败", "upload", err.Error())
+		return info, &TusError{http.StatusInternalServerError, "文件上传失败"}
+	}
+	defer file.Close()
+
+	// 不允许超过创建时声明的大小
+	var writer io.Writer = file
+	if checksumHash != nil {
+		writer = io.MultiWriter(file, checksumHash)
+	}
+	written, copyErr := io.Copy(writer, io.LimitReader(body, info.Length-offset))
+
+	// 有校验值时数据必须完整且一致，否则回退到本次写入前
+	if checksumHash != nil && (copyErr != nil || string(checksumHash.Sum(nil)) != string(expected)) {
+		file.Truncate(offset)
+		if copyErr != nil {
+			return info, &TusError{http.StatusBadRequest, "文件上传失败"}
+		}
+		return info, &TusError{460, "文件校验失败"}
+	}
+
+	// 连接中断时保留已收到的数据，客户端可从新的位置继续
+	info.Offset = offset + written
+	if copyErr != nil {
+		utils.ErrorLog("接收上传数据失败", "upload", copyErr.Error())
+		return info, &TusError{http.StatusBadRequest, "文件上传失败"}
+	}
+
+	if info.Offset == info.Length {
+		file.Close()
+		uploadPath := "./upload/video/" + fileInfo.DirName + "/upload.mp4"
+		if err := os.Rename(filePath, uploadPath); err != nil {
+			utils.ErrorLog("保存上传文件失败", "upload", err.Error())
+			return info, &TusError{http.StatusInternalServerError, "文件上传失败"}
+		}
+		tusLocks.Delete(id)
+
+		// 客户端提供的hash与文件md5一致时标记为已校验
+		if fileMd5, err := utils.FileMD5(uploadPath); err == nil && strings.EqualFold(fileMd5, fileInfo.Hash) {
+			global.Mysql.Model(&model.VideoFile{}).Where("id = ?", fileInfo.ID).Update("verified", true)
+		}
+	}
+
+	return info, nil
+}
+
+// 终止上传并删除文件，已用于投稿的文件不能删除
+func TusTerminateUpload(ctx *gin.Context, id string) error {
+	fileInfo, err := findTusUpload(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	global.Mysql.Model(&model.TranscodingTask{}).Where("dir_name = ?", fileInfo.DirName).Count(&count)
+	if count > 0 {
+		return &TusError{http.StatusForbidden, "视频文件已被使用"}
+	}
+
+	if err := global.Mysql.Where("id = ?", fileInfo.ID).Delete(&model.VideoFile{}).Error; err != nil {
+		utils.ErrorLog("删除视频文件信息失败", "upload", err.Error())
+		return &TusError{http.StatusInternalServerError, "删除失败"}
+	}
+	os.RemoveAll("./upload/video/" + fileInfo.DirName)
+	tusLocks.Delete(id)
+
+	return nil
+}
+
+// 查找当前用户的上传
+func findTusUpload(ctx *gin.Context, id string) (model.VideoFile, error) {
+	var fileInfo model.VideoFile
+	userId := ctx.GetUint("userId")
+	global.Mysql.Where("dir_name = ? and uid = ?", id, userId).Limit(1).Find(&fileInfo)
+	if fileInfo.ID == 0 {
+		return fileInfo, &TusError{http.StatusNotFound, "上传不存在"}
+	}
+
+	return fileInfo, nil
+}
+
+// 已上传的大小由磁盘上的文件得出，服务重启后仍可继续
+func getTusUploadInfo(fileInfo model.VideoFile) TusUploadInfo {
+	info := TusUploadInfo{ID: fileInfo.DirName, Length: fileInfo.Size}
+	dir := "./upload/video/" + fileInfo.DirName + "/"
+	if stat, err := os.Stat(dir + "upload.mp4"); err == nil {
+		info.Offset = stat.Size()
+		info.Length = stat.Size()
+	} else if stat, err := os.Stat(dir + TUS_PART_FILE); err == nil {
+		info.Offset = stat.Size()
+	}
+
+	return info
+}
+
+func isTusUploadCompleted(dirName string) bool {
+	return utils.IsFileExists("./upload/video/" + dirName + "/upload.mp4")
+}
+
+// 解析Upload-Metadata，格式为 "key base64(value),key base64(value)"
+func parseTusMetadata(metadata string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(metadata, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		result[key] = string(decoded)
+	}
+
+	return result
+}
+
+func newTusChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha256":
+		return sha256.New()
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTusMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata string
+		want     map[string]string
+	}{
+		{
+			name:     "空",
+			metadata: "",
+			want:     map[string]string{},
+		},
+		{
+			name:     "多个字段",
+			metadata: "filename dGVzdC5tcDQ=,hash YWJjMTIz",
+			want:     map[string]string{"filename": "test.mp4", "hash": "abc123"},
+		},
+		{
+			name:     "空格及空值",
+			metadata: " filename dGVzdC5tcDQ= , is_confidential",
+			want:     map[string]string{"filename": "test.mp4", "is_confidential": ""},
+		},
+		{
+			name:     "中文文件名",
+			metadata: "filename 6KeG6aKRLm1wNA==",
+			want:     map[string]string{"filename": "视频.mp4"},
+		},
+		{
+			name:     "跳过无效的base64",
+			metadata: "filename !!!,hash YWJjMTIz",
+			want:     map[string]string{"hash": "abc123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTusMetadata(tt.metadata); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTusMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package service
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"os"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"interastral-peace.com/alnitak/internal/cache"
@@ -51,27 +55,41 @@ func UploadImg(ctx *gin.Context, file *multipart.FileHeader) (string, error) {
 	return url, nil
 }
 
-func UploadVideoCreate(ctx *gin.Context, videoFileReq dto.VideoFileReq) (vo.ResourceResp, error) {
+func UploadVideoCreate(ctx *gin.Context, videoFileReq dto.VideoFileReq) (vo.ResourceResp, []string, error) {
 	userId := ctx.GetUint("userId")
 	var fileInfo model.VideoFile
 	if err := global.Mysql.Where("hash = ? and uid = ?", videoFileReq.Hash, userId).Find(&fileInfo).Error; err != nil {
 		utils.ErrorLog("视频文件信息不存在", "upload", videoFileReq.Hash)
-		return vo.ResourceResp{}, errors.New("视频文件不存在")
+		return vo.ResourceResp{}, nil, errors.New("视频文件不存在")
+	}
+
+	return createUploadVideo(userId, fileInfo, videoFileReq.Watermark)
+}
+
+// 使用已上传的文件创建视频，返回资源及候选封面
+func createUploadVideo(userId uint, fileInfo model.VideoFile, watermark bool) (vo.ResourceResp, []string, error) {
+	// 创建视频前校验视频文件，OSS直传的文件直接读取OSS中的文件
+	uploadVideoPath := getSourceVideoInput(fileInfo.DirName)
+	transcodingInfo, err := ProcessVideoInfo(uploadVideoPath)
+	if err != nil {
+		if errors.Is(err, ErrNoVideoStream) {
+			return vo.ResourceResp{}, nil, err
+		}
+		return vo.ResourceResp{}, nil, errors.New("读取视频信息失败")
 	}
 
 	// 先创建视频记录
-	uploadVideoPath := "./upload/video/" + fileInfo.DirName + "/upload.mp4"
-	vid, _ := initVideo(userId, uploadVideoPath, fileInfo.OriginalName)
+	vid, covers, _ := initVideo(userId, uploadVideoPath, fileInfo.OriginalName, transcodingInfo.Duration)
 	if vid == 0 {
-		return vo.ResourceResp{}, errors.New("创建失败")
+		return vo.ResourceResp{}, nil, errors.New("创建失败")
 	}
 
-	resource, err := CompleteUploadVideo(vid, userId, fileInfo.DirName, fileInfo.OriginalName)
+	resource, err := completeUploadVideo(vid, userId, fileInfo.DirName, fileInfo.OriginalName, watermark, transcodingInfo)
 	if err != nil {
-		return vo.ResourceResp{}, err
+		return vo.ResourceResp{}, nil, err
 	}
 
-	return resource, nil
+	return resource, covers, nil
 }
 
 func UploadVideoAdd(ctx *gin.Context, vid uint, videoFileReq dto.VideoFileReq) (vo.ResourceResp, error) {
@@ -82,7 +100,7 @@ func UploadVideoAdd(ctx *gin.Context, vid uint, videoFileReq dto.VideoFileReq) (
 		return vo.ResourceResp{}, errors.New("视频文件不存在")
 	}
 
-	resource, err := CompleteUploadVideo(vid, userId, fileInfo.DirName, fileInfo.OriginalName)
+	resource, err := CompleteUploadVideo(vid, userId, fileInfo.DirName, fileInfo.OriginalName, videoFileReq.Watermark)
 	if err != nil {
 		return vo.ResourceResp{}, err
 	}
@@ -90,23 +108,31 @@ func UploadVideoAdd(ctx *gin.Context, vid uint, videoFileReq dto.VideoFileReq) (
 	return resource, nil
 }
 
-func UploadVideoCheck(ctx *gin.Context, videoFileReq dto.VideoFileReq) ([]int, error) {
+// 获取已上传的分片，可以秒传时instant为true
+func UploadVideoCheck(ctx *gin.Context, videoFileReq dto.VideoFileReq) ([]int, bool, error) {
 	userId := ctx.GetUint("userId")
 	var fileInfo model.VideoFile
 	if err := global.Mysql.Where("hash = ? and uid = ?", videoFileReq.Hash, userId).Find(&fileInfo).Error; err != nil {
 		utils.ErrorLog("视频文件信息不存在", "upload", videoFileReq.Hash)
-		return nil, errors.New("视频文件不存在")
+		return nil, false, errors.New("视频文件不存在")
 	}
 
-	var checks []int
+	// 没有上传过时尝试秒传
+	if fileInfo.ID == 0 && videoFileReq.Name != "" {
+		if instantUploadVideo(userId, videoFileReq.Hash, videoFileReq.Name) {
+			return nil, true, nil
+		}
+	}
+
+	var chunks []int
 	fileDir := "./upload/video/" + fileInfo.DirName
 	for i := 0; i < fileInfo.ChunksCount; i++ {
 		if utils.IsFileExists(fmt.Sprintf("%s/chunks/%d.part", fileDir, i)) {
-			checks = append(checks, i)
+			chunks = append(chunks, i)
 		}
 	}
 
-	return checks, nil
+	return chunks, false, nil
 }
 
 func UploadVideoChunk(ctx *gin.Context, file *multipart.FileHeader) error {
@@ -115,6 +141,7 @@ func UploadVideoChunk(ctx *gin.Context, file *multipart.FileHeader) error {
 	// 获取分片信息
 	fileHash := ctx.PostForm("hash")
 	fileName := ctx.PostForm("name")
+	chunkHash := ctx.PostForm("chunkHash")
 	chunkIndex, _ := strconv.Atoi(ctx.PostForm("chunkIndex"))
 	totalChunks, _ := strconv.Atoi(ctx.PostForm("totalChunks"))
 
@@ -123,6 +150,10 @@ func UploadVideoChunk(ctx *gin.Context, file *multipart.FileHeader) error {
 		return errors.New("视频上传失败")
 	}
 
+	if totalChunks <= 0 || chunkIndex < 0 || chunkIndex >= totalChunks {
+		return errors.New("分片信息有误")
+	}
+
 	if !utils.FileSize(file.Size, int64(totalChunks), global.Config.File.MaxVideoSize) {
 		return errors.New("文件大小超出限制")
 	}
@@ -135,68 +166,165 @@ func UploadVideoChunk(ctx *gin.Context, file *multipart.FileHeader) error {
 		dirName = generateVideoFilename()
 		global.Mysql.Create(&model.VideoFile{Uid: userId, Hash: fileHash, DirName: dirName, OriginalName: fileName, ChunksCount: totalChunks})
 	} else {
+		// 分片数量以第一次上传时为准
+		if videoFileInfo.ChunksCount != totalChunks {
+			return errors.New("分片数量不一致")
+		}
 		dirName = videoFileInfo.DirName
 	}
 
-	fileDir := "./upload/video/" + dirName
-	chunksPath := fileDir + "/chunks/" + strconv.Itoa(chunkIndex) + ".part"
-	if err := ctx.SaveUploadedFile(file, chunksPath); err != nil {
+	chunksDir := "./upload/video/" + dirName + "/chunks/"
+	if err := saveChunk(file, chunksDir, chunkIndex, chunkHash); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// 保存分片，先写入临时文件并计算md5，校验通过后重命名，避免残缺的分片被视为已上传
+func saveChunk(file *multipart.FileHeader, chunksDir string, chunkIndex int, chunkHash string) error {
+	src, err := file.Open()
+	if err != nil {
+		return errors.New("文件上传失败")
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(chunksDir, os.ModePerm); err != nil {
+		utils.ErrorLog("创建分片文件夹失败", "upload", err.Error())
+		return errors.New("文件上传失败")
+	}
+	chunkName := strconv.Itoa(chunkIndex) + ".part"
+	tmp, err := os.CreateTemp(chunksDir, chunkName+".*")
+	if err != nil {
+		utils.ErrorLog("创建分片文件失败", "upload", err.Error())
+		return errors.New("文件上传失败")
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		utils.ErrorLog("保存分片失败", "upload", err.Error())
+		return errors.New("文件上传失败")
+	}
+
+	if chunkHash != "" && !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), chunkHash) {
+		return errors.New("分片校验失败")
+	}
+
+	if err := os.Rename(tmp.Name(), chunksDir+chunkName); err != nil {
+		utils.ErrorLog("保存分片失败", "upload", err.Error())
 		return errors.New("文件上传失败")
 	}
 
 	return nil
 }
 
-func UploadVideoMerge(ctx *gin.Context, videoFileReq dto.VideoFileReq) error {
+// 合并视频分片，有分片缺失时返回缺失的分片序号
+func UploadVideoMerge(ctx *gin.Context, videoFileReq dto.VideoFileReq) ([]int, error) {
 	userId := ctx.GetUint("userId")
 	var fileInfo model.VideoFile
-	if err := global.Mysql.Where("hash = ? and uid = ?", videoFileReq.Hash, userId).Find(&fileInfo).Error; err != nil {
+	global.Mysql.Where("hash = ? and uid = ?", videoFileReq.Hash, userId).First(&fileInfo)
+	if fileInfo.ID == 0 {
 		utils.ErrorLog("视频文件信息不存在", "upload", videoFileReq.Hash)
-		return errors.New("视频文件不存在")
+		return nil, errors.New("视频文件不存在")
 	}
 
+	// 已合并并校验过的文件不再重复合并
 	fileDir := "./upload/video/" + fileInfo.DirName
-	if err := mergeChunks(fileDir, fileInfo.ChunksCount); err != nil {
+	if fileInfo.Verified && utils.IsFileExists(fileDir+"/upload.mp4") {
+		return nil, nil
+	}
+
+	missing := make([]int, 0)
+	for i := 0; i < fileInfo.ChunksCount; i++ {
+		if !utils.IsFileExists(fmt.Sprintf("%s/chunks/%d.part", fileDir, i)) {
+			missing = append(missing, i)
+		}
+	}
+	if fileInfo.ChunksCount <= 0 || len(missing) > 0 {
+		return missing, errors.New("视频分片缺失")
+	}
+
+	fileHash, err := mergeChunks(fileDir, fileInfo.ChunksCount)
+	if err != nil {
 		utils.ErrorLog("合并分片失败", "upload", err.Error())
-		return errors.New("合并分片失败")
+		os.Remove(fileDir + "/upload.mp4.tmp")
+		return nil, errors.New("合并分片失败")
 	}
 
+	// 与上传时的文件hash不一致时删除分片，需要重新上传
+	if !strings.EqualFold(fileHash, fileInfo.Hash) {
+		utils.ErrorLog("视频文件校验失败", "upload", fileInfo.Hash+" "+fileHash)
+		os.Remove(fileDir + "/upload.mp4.tmp")
+		os.RemoveAll(fileDir + "/chunks/")
+		return nil, errors.New("视频文件校验失败，请重新上传")
+	}
+
+	if err := os.Rename(fileDir+"/upload.mp4.tmp", fileDir+"/upload.mp4"); err != nil {
+		utils.ErrorLog("保存视频文件失败", "upload", err.Error())
+		return nil, errors.New("合并分片失败")
+	}
+	global.Mysql.Model(&model.VideoFile{}).Where("id = ?", fileInfo.ID).Update("verified", true)
+
 	if err := os.RemoveAll(fileDir + "/chunks/"); err != nil {
 		utils.ErrorLog("删除临时文件夹失败", "upload", err.Error())
 	}
 
-	return nil
+	return nil, nil
 }
 
-func mergeChunks(fileDir string, totalChunks int) error {
-	outputPath := fileDir + "/upload.mp4"
-	outFile, err := os.Create(outputPath)
+// 依次写入分片并计算整个文件的md5
+func mergeChunks(fileDir string, totalChunks int) (string, error) {
+	outFile, err := os.Create(fileDir + "/upload.mp4.tmp")
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer outFile.Close()
 
+	hash := md5.New()
+	writer := io.MultiWriter(outFile, hash)
 	for i := 0; i < totalChunks; i++ {
-		chunkPath := fmt.Sprintf("%s/chunks/%d.part", fileDir, i)
-		chunk, err := os.ReadFile(chunkPath)
-		if err != nil {
-			return err
-		}
-		if _, err := outFile.Write(chunk); err != nil {
-			return err
+		if err := appendChunk(writer, fmt.Sprintf("%s/chunks/%d.part", fileDir, i)); err != nil {
+			return "", err
 		}
 	}
+	if err := outFile.Close(); err != nil {
+		return "", err
+	}
 
-	return nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func CompleteUploadVideo(vid, userId uint, videoName, title string) (vo.ResourceResp, error) {
-	uploadVideoPath := "./upload/video/" + videoName + "/upload.mp4"
+func appendChunk(writer io.Writer, chunkPath string) error {
+	chunk, err := os.Open(chunkPath)
+	if err != nil {
+		return err
+	}
+	defer chunk.Close()
+
+	_, err = io.Copy(writer, chunk)
+	return err
+}
+
+func CompleteUploadVideo(vid, userId uint, videoName, title string, watermark bool) (vo.ResourceResp, error) {
+	uploadVideoPath := getSourceVideoInput(videoName)
 	transcodingInfo, err := ProcessVideoInfo(uploadVideoPath)
 	if err != nil {
+		if errors.Is(err, ErrNoVideoStream) {
+			return vo.ResourceResp{}, err
+		}
 		return vo.ResourceResp{}, errors.New("读取视频信息失败")
 	}
 
+	return completeUploadVideo(vid, userId, videoName, title, watermark, transcodingInfo)
+}
+
+// 使用已读取的视频信息创建资源并加入转码队列
+func completeUploadVideo(vid, userId uint, videoName, title string, watermark bool, transcodingInfo *dto.TranscodingInfo) (vo.ResourceResp, error) {
 	// 去掉后缀名
 	titleWithoutExt := title[:len(title)-len(path.Ext(title))]
 
@@ -208,18 +336,28 @@ func CompleteUploadVideo(vid, userId uint, videoName, title string) (vo.Resource
 		CodecName: transcodingInfo.CodecName,
 		Status:    global.VIDEO_PROCESSING,
 		Duration:  transcodingInfo.Duration,
+		Watermark: watermark,
 	}
 	if err := global.Mysql.Create(&resource).Error; err != nil {
 		return vo.ResourceResp{}, errors.New("保存视频失败")
 	}
 
-	// 启动转码服务
-	transcodingInfo.VideoID = vid
-	transcodingInfo.DirName = videoName
-	transcodingInfo.ResourceID = resource.ID
-	transcodingInfo.OutputDir = "./upload/video/" + videoName + "/"
-	transcodingInfo.InputFile = transcodingInfo.OutputDir + "upload.mp4"
-	go VideoTransCoding(transcodingInfo)
+	// 相同源文件已有转码完成的资源时直接复用，不需要重新转码
+	if !watermark {
+		if source := findReusableResource(videoName); source.ID != 0 {
+			if err := reuseTranscodedFiles(resource, source, videoName, transcodingInfo); err == nil {
+				completeTransCoding(vid, resource.ID, global.WAITING_REVIEW)
+				resource.Status = global.WAITING_REVIEW
+				return vo.ResourceToResourceResp(resource), nil
+			}
+		}
+	}
+
+	// 加入转码队列
+	if err := AddTranscodingTask(vid, resource.ID, videoName); err != nil {
+		completeTransCoding(vid, resource.ID, global.PROCESSING_FAIL)
+		return vo.ResourceResp{}, err
+	}
 
 	return vo.ResourceToResourceResp(resource), nil
 }
@@ -233,33 +371,40 @@ func generateFileUrl(objectKey string) string {
 	return "/api/" + objectKey
 }
 
-// 初始化视频
-func initVideo(userId uint, videoPath, title string) (uint, error) {
-	// 生成封面
-	coverName := generateImgFilename(".jpg")
-	objectKey := "image/" + coverName
-	filePath := "./upload/image/" + coverName
-
-	GenerateCover(videoPath, filePath)
-	if global.Config.Storage.OssType != "local" {
-		// 上传到OSS
-		global.Storage.PutObjectFromFile(objectKey, filePath)
+// 初始化视频，返回视频ID及候选封面
+func initVideo(userId uint, videoPath, title string, duration float64) (uint, []string, error) {
+	// 生成候选封面，默认使用第一张
+	var cover string
+	covers := generateCoverCandidates(userId, videoPath, duration)
+	if len(covers) > 0 {
+		cover = covers[0]
+	} else {
+		coverName := generateImgFilename(".jpg")
+		objectKey := "image/" + coverName
+		filePath := "./upload/image/" + coverName
+
+		GenerateCover(videoPath, filePath)
+		if global.Config.Storage.OssType != "local" {
+			// 上传到OSS
+			global.Storage.PutObjectFromFile(objectKey, filePath)
+		}
+		cover = generateFileUrl(objectKey)
 	}
 	// 去掉后缀名
 	titleWithoutExt := title[:len(title)-len(path.Ext(title))]
 
 	videoId, err := CreateVideo(&model.Video{
 		Uid:       userId,
-		Cover:     generateFileUrl(objectKey),
+		Cover:     cover,
 		Title:     titleWithoutExt,
 		Copyright: true,
 		Status:    global.CREATED_VIDEO,
 	})
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	return videoId, nil
+	return videoId, covers, nil
 }
 
 // 随机生成图片文件名
@@ -2,10 +2,8 @@ package service
 
 import (
 	"errors"
-	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"interastral-peace.com/alnitak/internal/cache"
 	"interastral-peace.com/alnitak/internal/domain/dto"
 	"interastral-peace.com/alnitak/internal/domain/model"
@@ -60,6 +58,13 @@ func GetVideoStatus(ctx *gin.Context, vid uint) (video vo.VideoStatusResp, err e
 	//查询分区下的视频资源
 	video.Resources = GetReviewResourceList(vid)
 
+	// 转码中的资源附带转码进度
+	for i := range video.Resources {
+		if video.Resources[i].Status == global.VIDEO_PROCESSING {
+			video.Resources[i].Progress = cache.GetTranscodingProgress(video.Resources[i].ID)
+		}
+	}
+
 	return video, nil
 }
 
@@ -72,19 +77,7 @@ func GetVideoFile(ctx *gin.Context, resourceId uint, quality string) (string, er
 	var file model.VideoIndexFile
 	global.Mysql.Where("resource_id = ? and quality = ?", resourceId, quality).First(&file)
 
-	res := ""
-	key := uuid.New().String()
-	cache.SetVideoSlice(key, file.DirName)
-	for _, line := range strings.Split(file.Content, "\n") {
-		//根据关键词覆盖当前行
-		if strings.Contains(line, ".ts") {
-			res += "/api/v1/video/slice/" + line + "?key=" + key + "\n"
-		} else {
-			res += line + "\n"
-		}
-	}
-
-	return res, nil
+	return rewriteVideoIndexFile(file), nil
 }
 
 // 获取视频文件（后台管理）
@@ -92,19 +85,7 @@ func GetVideoFileManage(ctx *gin.Context, resourceId uint, quality string) (stri
 	var file model.VideoIndexFile
 	global.Mysql.Where("resource_id = ? and quality = ?", resourceId, quality).First(&file)
 
-	res := ""
-	key := uuid.New().String()
-	cache.SetVideoSlice(key, file.DirName)
-	for _, line := range strings.Split(file.Content, "\n") {
-		//根据关键词覆盖当前行
-		if strings.Contains(line, ".ts") {
-			res += "/api/v1/video/slice/" + line + "?key=" + key + "\n"
-		} else {
-			res += line + "\n"
-		}
-	}
-
-	return res, nil
+	return rewriteVideoIndexFile(file), nil
 }
 
 // 获取视频切所在文件目录
@@ -173,6 +154,14 @@ func DeleteVideo(ctx *gin.Context, id uint) error {
 		return errors.New("删除视频失败")
 	}
 
+	// 取消进行中的转码
+	CancelVideoTranscoding(id)
+
+	// 释放不再被引用的视频文件
+	var resources []model.Resource
+	global.Mysql.Where("vid = ?", id).Find(&resources)
+	go releaseVideoFiles(resources)
+
 	// 删除缓存中的视频ID信息
 	cache.DelVideoId(video.PartitionId, video.ID)
 
@@ -242,12 +231,32 @@ func DeleteVideoManage(ctx *gin.Context, id uint) error {
 		return errors.New("删除视频失败")
 	}
 
+	// 取消进行中的转码
+	CancelVideoTranscoding(id)
+
+	// 释放不再被引用的视频文件
+	var resources []model.Resource
+	global.Mysql.Where("vid = ?", id).Find(&resources)
+	go releaseVideoFiles(resources)
+
 	// 删除视频信息缓存
 	cache.DelVideoInfo(id)
 
 	return nil
 }
 
+// 获取转码任务列表(后台管理)
+func GetTranscodingTaskListManage(taskListReq dto.TranscodingTaskListReq) (total int64, tasks []vo.TranscodingTaskResp) {
+	query := global.Mysql.Model(&model.TranscodingTask{})
+	if taskListReq.Status != nil {
+		query = query.Where("status = ?", *taskListReq.Status)
+	}
+	query.Count(&total)
+	query.Order("id desc").Limit(taskListReq.PageSize).Offset((taskListReq.Page - 1) * taskListReq.PageSize).Scan(&tasks)
+
+	return
+}
+
 // 获取待审核视频列表
 func GetReviewList(reviewListReq dto.ReviewListReq) (total int64, videos []vo.ReviewListResp) {
 	global.Mysql.Model(&model.Video{}).Where("status = ?", global.WAITING_REVIEW).Count(&total)
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/domain/vo"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	VIDEO_IMPORT_DOWNLOADING = "downloading" // 下载中
+	VIDEO_IMPORT_PROCESSING  = "processing"  // 下载完成，创建视频中
+	VIDEO_IMPORT_DONE        = "done"        // 已加入转码队列
+	VIDEO_IMPORT_FAILED      = "failed"      // 导入失败
+
+	VIDEO_IMPORT_DEFAULT_TIMEOUT   = 30 * time.Minute // 未配置时的下载超时时间
+	VIDEO_IMPORT_CONCURRENCY       = 3                // 同时下载的数量
+	VIDEO_IMPORT_USER_LIMIT        = 3                // 每个用户未完成的导入数量
+	VIDEO_IMPORT_MAX_REDIRECTS     = 5                // 最大重定向次数
+	VIDEO_IMPORT_SNIFF_SIZE        = 512              // 判断文件类型读取的字节数
+	VIDEO_IMPORT_PROGRESS_INTERVAL = time.Second      // 进度更新间隔
+)
+
+var (
+	videoImportSemaphore = make(chan struct{}, VIDEO_IMPORT_CONCURRENCY)
+
+	// 用户未完成的导入数量，用户ID -> 数量
+	videoImportPending = make(map[uint]int)
+	videoImportMutex   sync.Mutex
+
+	errImportAddressDenied = errors.New("不允许从该地址导入")
+)
+
+// 从URL导入视频，返回导入ID，下载在后台进行
+func ImportVideo(ctx *gin.Context, importReq dto.ImportVideoReq) (string, error) {
+	userId := ctx.GetUint("userId")
+	u, err := url.Parse(strings.TrimSpace(importReq.Url))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", errors.New("视频地址有误")
+	}
+
+	if importReq.Vid != 0 {
+		var video model.Video
+		global.Mysql.Model(&model.Video{}).Where("id = ? and uid = ?", importReq.Vid, userId).First(&video)
+		if video.ID == 0 {
+			return "", errors.New("视频不存在")
+		}
+	}
+
+	// 提前检查地址，下载时在建立连接前会再次检查解析到的IP
+	if err := checkImportHost(u.Hostname()); err != nil {
+		return "", err
+	}
+
+	if !acquireVideoImport(userId) {
+		return "", errors.New("导入任务过多，请等待之前的导入完成")
+	}
+
+	progress := vo.VideoImportResp{
+		ID:     generateVideoFilename(),
+		Uid:    userId,
+		Status: VIDEO_IMPORT_DOWNLOADING,
+		Vid:    importReq.Vid,
+	}
+	cache.SetVideoImport(progress)
+
+	go runVideoImport(u, importReq, progress)
+
+	return progress.ID, nil
+}
+
+// 获取导入进度
+func GetVideoImport(ctx *gin.Context, id string) (vo.VideoImportResp, error) {
+	userId := ctx.GetUint("userId")
+	progress := cache.GetVideoImport(id)
+	if progress.ID == "" || progress.Uid != userId {
+		return progress, errors.New("导入任务不存在")
+	}
+
+	return progress, nil
+}
+
+func runVideoImport(u *url.URL, importReq dto.ImportVideoReq, progress vo.VideoImportResp) {
+	defer releaseVideoImport(progress.Uid)
+	videoImportSemaphore <- struct{}{}
+	defer func() { <-videoImportSemaphore }()
+
+	dirName := progress.ID
+	fileHash, fileName, err := downloadImportVideo(u, dirName, &progress)
+	if err != nil {
+		os.RemoveAll("./upload/video/" + dirName)
+		failVideoImport(&progress, err.Error())
+		return
+	}
+
+	progress.Status = VIDEO_IMPORT_PROCESSING
+	cache.SetVideoImport(progress)
+
+	fileInfo, err := saveImportVideoFile(progress.Uid, dirName, fileHash, fileName, progress.Received)
+	if err != nil {
+		os.RemoveAll("./upload/video/" + dirName)
+		failVideoImport(&progress, err.Error())
+		return
+	}
+
+	var resource vo.ResourceResp
+	if importReq.Vid == 0 {
+		resource, _, err = createUploadVideo(progress.Uid, fileInfo, importReq.Watermark)
+	} else {
+		resource, err = CompleteUploadVideo(importReq.Vid, progress.Uid, fileInfo.DirName, fileInfo.OriginalName, importReq.Watermark)
+	}
+	if err != nil {
+		// 下载的文件无法创建视频时删除，避免之后被秒传或去重使用
+		if fileInfo.DirName == dirName && !isVideoDirReferenced(dirName) {
+			global.Mysql.Where("id = ?", fileInfo.ID).Delete(&model.VideoFile{})
+			removeTranscodingFiles(dirName, false)
+		}
+		failVideoImport(&progress, err.Error())
+		return
+	}
+
+	progress.Status = VIDEO_IMPORT_DONE
+	progress.Vid = resource.Vid
+	progress.Resource = &resource
+	cache.SetVideoImport(progress)
+}
+
+// 占用用户的导入数量，超出限制时返回false
+func acquireVideoImport(userId uint) bool {
+	videoImportMutex.Lock()
+	defer videoImportMutex.Unlock()
+	if videoImportPending[userId] >= VIDEO_IMPORT_USER_LIMIT {
+		return false
+	}
+
+	videoImportPending[userId]++
+	return true
+}
+
+func releaseVideoImport(userId uint) {
+	videoImportMutex.Lock()
+	defer videoImportMutex.Unlock()
+	if videoImportPending[userId]--; videoImportPending[userId] <= 0 {
+		delete(videoImportPending, userId)
+	}
+}
+
+func failVideoImport(progress *vo.VideoImportResp, msg string) {
+	utils.ErrorLog("导入视频失败", "upload", msg)
+	progress.Status = VIDEO_IMPORT_FAILED
+	progress.Message = msg
+	cache.SetVideoImport(*progress)
+}
+
+// 下载视频到目录中，返回文件md5及文件名
+func downloadImportVideo(u *url.URL, dirName string, progress *vo.VideoImportResp) (string, string, error) {
+	timeout := time.Duration(global.Config.File.Import.Timeout) * time.Minute
+	if timeout <= 0 {
+		timeout = VIDEO_IMPORT_DEFAULT_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", errors.New("视频地址有误")
+	}
+	res, err := newImportHttpClient().Do(req)
+	if err != nil {
+		if errors.Is(err, errImportAddressDenied) {
+			return "", "", errImportAddressDenied
+		}
+		utils.ErrorLog("下载视频失败", "upload", err.Error())
+		return "", "", errors.New("下载视频失败")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", "", errors.New("下载视频失败，服务器返回" + res.Status)
+	}
+
+	maxSize := global.Config.File.MaxVideoSize * utils.MB
+	if res.ContentLength > maxSize {
+		return "", "", errors.New("文件大小超出限制")
+	}
+	if res.ContentLength > 0 {
+		progress.Total = res.ContentLength
+	}
+
+	// 根据文件头判断是否为视频
+	head := make([]byte, VIDEO_IMPORT_SNIFF_SIZE)
+	n, err := io.ReadFull(res.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", "", errors.New("下载视频失败")
+	}
+	head = head[:n]
+	if !isImportVideoContent(head, res.Header.Get("Content-Type")) {
+		return "", "", errors.New("文件类型错误")
+	}
+
+	fileDir := "./upload/video/" + dirName
+	if err := os.MkdirAll(fileDir, os.ModePerm); err != nil {
+		utils.ErrorLog("创建上传目录失败", "upload", err.Error())
+		return "", "", errors.New("下载视频失败")
+	}
+	file, err := os.Create(fileDir + "/upload.mp4.tmp")
+	if err != nil {
+		utils.ErrorLog("创建视频文件失败", "upload", err.Error())
+		return "", "", errors.New("下载视频失败")
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	writer := io.MultiWriter(file, hash, &importProgressWriter{progress: progress})
+	if _, err := writer.Write(head); err != nil {
+		return "", "", errors.New("下载视频失败")
+	}
+
+	// 多读取一个字节用于判断是否超出大小限制
+	if _, err := io.Copy(writer, io.LimitReader(res.Body, maxSize-int64(n)+1)); err != nil {
+		if ctx.Err() != nil {
+			return "", "", errors.New("下载超时")
+		}
+		utils.ErrorLog("下载视频失败", "upload", err.Error())
+		return "", "", errors.New("下载视频失败")
+	}
+	if progress.Received > maxSize {
+		return "", "", errors.New("文件大小超出限制")
+	}
+	if progress.Total > 0 && progress.Received != progress.Total {
+		return "", "", errors.New("下载视频失败")
+	}
+
+	file.Close()
+	if err := os.Rename(fileDir+"/upload.mp4.tmp", fileDir+"/upload.mp4"); err != nil {
+		utils.ErrorLog("保存视频文件失败", "upload", err.Error())
+		return "", "", errors.New("下载视频失败")
+	}
+	progress.Total = progress.Received
+	cache.SetVideoImport(*progress)
+
+	return hex.EncodeToString(hash.Sum(nil)), getImportFileName(u, res.Header.Get("Content-Disposition")), nil
+}
+
+// 保存视频文件信息，已上传过相同文件时使用之前的文件
+func saveImportVideoFile(userId uint, dirName, fileHash, fileName string, size int64) (model.VideoFile, error) {
+	var fileInfo model.VideoFile
+	global.Mysql.Where("uid = ? and hash = ?", userId, fileHash).Limit(1).Find(&fileInfo)
+	// 添加校验之前上传的文件已被使用过时同样视为完整的文件
+	if fileInfo.ID != 0 && (fileInfo.Verified || isVideoDirReferenced(fileInfo.DirName)) && fetchSourceVideo(fileInfo.DirName) == nil {
+		os.RemoveAll("./upload/video/" + dirName)
+		fileInfo.OriginalName = fileName
+		return fileInfo, nil
+	}
+
+	// 未完成的上传改为使用下载的文件
+	if fileInfo.ID != 0 && !isVideoDirReferenced(fileInfo.DirName) {
+		os.RemoveAll("./upload/video/" + fileInfo.DirName)
+	}
+	fileInfo.Uid = userId
+	fileInfo.Hash = fileHash
+	fileInfo.DirName = dirName
+	fileInfo.OriginalName = fileName
+	fileInfo.Size = size
+	fileInfo.ChunksCount = 0
+	fileInfo.UploadID = ""
+	fileInfo.Verified = true
+	if err := global.Mysql.Save(&fileInfo).Error; err != nil {
+		utils.ErrorLog("保存视频文件信息失败", "upload", err.Error())
+		return fileInfo, errors.New("保存视频失败")
+	}
+
+	return fileInfo, nil
+}
+
+// 下载使用的客户端，每次建立连接前检查地址，重定向后的地址同样会被检查
+func newImportHttpClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: checkImportAddress,
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil, // 不使用代理，否则检查的是代理的地址
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= VIDEO_IMPORT_MAX_REDIRECTS {
+				return errors.New("重定向次数过多")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errImportAddressDenied
+			}
+			return nil
+		},
+	}
+}
+
+// 建立连接前检查解析后的地址，避免DNS重绑定
+func checkImportAddress(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return errImportAddressDenied
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isImportIPAllowed(ip) {
+		return errImportAddressDenied
+	}
+
+	return nil
+}
+
+// 检查域名解析到的所有地址
+func checkImportHost(host string) error {
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return errors.New("无法解析视频地址")
+	}
+	for _, ip := range ips {
+		if !isImportIPAllowed(ip) {
+			return errImportAddressDenied
+		}
+	}
+
+	return nil
+}
+
+func isImportIPAllowed(ip net.IP) bool {
+	return global.Config.File.Import.AllowPrivate || utils.IsPublicIP(ip) ||
+		utils.IsIPInList(ip, global.Config.File.Import.AllowHosts)
+}
+
+// 文件头能识别为视频时通过，无法识别的格式(mov、flv、ts等)在服务器未声明为其他类型时交给ffprobe校验
+func isImportVideoContent(head []byte, contentType string) bool {
+	sniffed := http.DetectContentType(head)
+	if strings.HasPrefix(sniffed, "video/") || sniffed == "application/ogg" {
+		return true
+	}
+	if sniffed != "application/octet-stream" {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "" || strings.HasPrefix(mediaType, "video/") ||
+		mediaType == "application/octet-stream" || mediaType == "binary/octet-stream"
+}
+
+// 获取文件名，优先使用Content-Disposition中的文件名
+func getImportFileName(u *url.URL, contentDisposition string) string {
+	fileName := ""
+	if _, params, err := mime.ParseMediaType(contentDisposition); err == nil {
+		fileName = path.Base(params["filename"])
+	}
+	if fileName == "" || fileName == "." || fileName == "/" {
+		fileName = path.Base(u.Path)
+		if name, err := url.PathUnescape(fileName); err == nil {
+			fileName = name
+		}
+	}
+	if fileName == "" || fileName == "." || fileName == "/" {
+		fileName = "视频"
+	}
+
+	suffix := path.Ext(fileName)
+	if !utils.IsVideoType(suffix) {
+		fileName = strings.TrimSuffix(fileName, suffix) + ".mp4"
+		suffix = ".mp4"
+	}
+	if utf8.RuneCountInString(fileName) > 100 {
+		fileName = string([]rune(fileName)[:100-utf8.RuneCountInString(suffix)]) + suffix
+	}
+
+	return fileName
+}
+
+// 统计下载的字节数并定期更新进度
+type importProgressWriter struct {
+	progress *vo.VideoImportResp
+	updateAt time.Time
+}
+
+func (w *importProgressWriter) Write(p []byte) (int, error) {
+	w.progress.Received += int64(len(p))
+	if time.Since(w.updateAt) >= VIDEO_IMPORT_PROGRESS_INTERVAL {
+		w.updateAt = time.Now()
+		cache.SetVideoImport(*w.progress)
+	}
+
+	return len(p), nil
+}
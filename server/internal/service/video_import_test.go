@@ -0,0 +1,39 @@
+package service
+
+import "testing"
+
+func TestIsImportVideoContent(t *testing.T) {
+	mp4 := []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+	webm := []byte("\x1a\x45\xdf\xa3\x01\x00\x00\x00\x00\x00\x00\x1f\x42\x86\x81\x01")
+	ogg := []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00")
+	flv := []byte("FLV\x01\x05\x00\x00\x00\x09\x00\x00\x00\x00")
+	html := []byte("<!DOCTYPE html><html><body></body></html>")
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+	tests := []struct {
+		name        string
+		head        []byte
+		contentType string
+		want        bool
+	}{
+		{"MP4", mp4, "", true},
+		{"MP4声明为其他类型", mp4, "text/plain", true},
+		{"WebM", webm, "video/webm", true},
+		{"Ogg", ogg, "", true},
+		{"未识别格式未声明类型", flv, "", true},
+		{"未识别格式声明为视频", flv, "video/x-flv", true},
+		{"未识别格式声明为二进制", flv, "application/octet-stream", true},
+		{"未识别格式声明为S3二进制", flv, "binary/octet-stream; charset=utf-8", true},
+		{"未识别格式声明为网页", flv, "text/html", false},
+		{"网页", html, "video/mp4", false},
+		{"图片", png, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isImportVideoContent(tt.head, tt.contentType); got != tt.want {
+				t.Errorf("isImportVideoContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+	"interastral-peace.com/alnitak/internal/cache"
+	"interastral-peace.com/alnitak/internal/domain/model"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	VIDEO_KEY_FILE      = "enc.key"     // 转码时使用的密钥文件，不会上传
+	VIDEO_KEY_INFO_FILE = "enc.keyinfo" // ffmpeg密钥信息文件
+)
+
+// 生成切片加密密钥及ffmpeg所需的密钥文件
+func generateVideoKey(outputDir, dirName string, resourceId uint) (*model.VideoKey, error) {
+	secret, err := utils.GenerateSecureHex(16)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := utils.GenerateSecureHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes, _ := hex.DecodeString(secret)
+	if err := os.WriteFile(outputDir+VIDEO_KEY_FILE, keyBytes, 0600); err != nil {
+		utils.ErrorLog("写入密钥文件失败", "transcoding", err.Error())
+		return nil, err
+	}
+
+	// 密钥信息文件格式：密钥URI、密钥文件路径、IV
+	keyInfo := VIDEO_KEY_FILE + "\n" + VIDEO_KEY_FILE + "\n" + iv + "\n"
+	if err := os.WriteFile(outputDir+VIDEO_KEY_INFO_FILE, []byte(keyInfo), 0600); err != nil {
+		utils.ErrorLog("写入密钥信息文件失败", "transcoding", err.Error())
+		return nil, err
+	}
+
+	return &model.VideoKey{ResourceID: resourceId, DirName: dirName, Secret: secret, IV: iv}, nil
+}
+
+// 删除转码时使用的密钥文件
+func removeVideoKeyFiles(outputDir string) {
+	os.Remove(outputDir + VIDEO_KEY_FILE)
+	os.Remove(outputDir + VIDEO_KEY_INFO_FILE)
+}
+
+// 保存资源切片目录的密钥，被替换目录的密钥在目录清理时删除
+func saveVideoKey(tx *gorm.DB, videoKey *model.VideoKey) error {
+	if err := tx.Unscoped().Where("resource_id = ? and dir_name = ?", videoKey.ResourceID, videoKey.DirName).Delete(&model.VideoKey{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Create(videoKey).Error
+}
+
+// 获取切片解密密钥，需要有效的播放key
+func GetVideoKey(resourceId uint, key string) ([]byte, error) {
+	dir := cache.GetVideoSlice(key)
+	if dir == "" {
+		return nil, errors.New("播放凭证无效")
+	}
+
+	// 播放key只能获取对应目录的密钥，重新转码后旧目录的密钥保留到目录被清理
+	var videoKey model.VideoKey
+	if err := global.Mysql.Where("resource_id = ? and dir_name = ?", resourceId, dir).First(&videoKey).Error; err != nil {
+		return nil, errors.New("密钥不存在")
+	}
+
+	return hex.DecodeString(videoKey.Secret)
+}
+
+// 删除资源被替换目录的密钥
+func removeVideoKey(resourceId uint, dirName string) {
+	global.Mysql.Unscoped().Where("resource_id = ? and dir_name = ?", resourceId, dirName).Delete(&model.VideoKey{})
+}
+
+// 替换标签中的URI属性
+func replaceTagUri(line, uri string) string {
+	before, after, found := strings.Cut(line, "URI=\"")
+	if !found {
+		return line
+	}
+	_, rest, _ := strings.Cut(after, "\"")
+
+	return before + "URI=\"" + uri + "\"" + rest
+}
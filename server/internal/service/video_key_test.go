@@ -0,0 +1,45 @@
+package service
+
+import "testing"
+
+func TestReplaceTagUri(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		uri  string
+		want string
+	}{
+		{
+			name: "替换密钥地址",
+			line: `#EXT-X-KEY:METHOD=AES-128,URI="key.key",IV=0x00000000000000000000000000000001`,
+			uri:  "/api/v1/video/key?rid=1&key=abc",
+			want: `#EXT-X-KEY:METHOD=AES-128,URI="/api/v1/video/key?rid=1&key=abc",IV=0x00000000000000000000000000000001`,
+		},
+		{
+			name: "URI在末尾",
+			line: `#EXT-X-MAP:URI="720p_init.mp4"`,
+			uri:  "/slice/720p_init.mp4",
+			want: `#EXT-X-MAP:URI="/slice/720p_init.mp4"`,
+		},
+		{
+			name: "没有URI",
+			line: `#EXT-X-KEY:METHOD=NONE`,
+			uri:  "/api/v1/video/key",
+			want: `#EXT-X-KEY:METHOD=NONE`,
+		},
+		{
+			name: "URI缺少结束引号",
+			line: `#EXT-X-KEY:METHOD=AES-128,URI="key.key`,
+			uri:  "/key",
+			want: `#EXT-X-KEY:METHOD=AES-128,URI="/key"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceTagUri(tt.line, tt.uri); got != tt.want {
+				t.Errorf("replaceTagUri() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"interastral-peace.com/alnitak/internal/domain/dto"
+	"interastral-peace.com/alnitak/internal/global"
+	"interastral-peace.com/alnitak/utils"
+)
+
+const (
+	WATERMARK_TEXT_FILE  = "watermark.txt" // 文字水印内容文件
+	WATERMARK_MARGIN     = 0.02            // 水印边距占画面短边的比例
+	WATERMARK_FONT_RATIO = 0.04            // 文字大小占画面短边的比例
+)
+
+// 水印选项
+type watermarkOptions struct {
+	Image    string  // 图片水印绝对路径，为空时不添加
+	TextFile string  // 文字水印内容文件，为空时不添加
+	FontFile string  // 字体文件绝对路径
+	Position string  // 水印位置
+	Opacity  float64 // 不透明度
+	Scale    float64 // 图片水印宽度占视频宽度的比例
+	Width    int     // 画面宽度
+	Height   int     // 画面高度
+}
+
+// 获取水印选项，未开启或没有可用的水印时返回nil
+func getWatermarkOptions(transcodingInfo *dto.TranscodingInfo) *watermarkOptions {
+	conf := global.Config.Transcoding.Watermark
+	if !transcodingInfo.Watermark {
+		return nil
+	}
+
+	options := &watermarkOptions{
+		Position: conf.Position,
+		Opacity:  conf.Opacity,
+		Scale:    conf.Scale,
+		Width:    transcodingInfo.Width,
+		Height:   transcodingInfo.Height,
+	}
+
+	// 图片水印
+	if conf.Image != "" {
+		if image, err := filepath.Abs(conf.Image); err == nil && utils.IsFileExists(image) {
+			options.Image = image
+		} else {
+			utils.ErrorLog("水印图片不存在", "transcoding", conf.Image)
+		}
+	}
+
+	// 文字水印，写入文件以避免用户名中的特殊字符被滤镜解析
+	if conf.Text && transcodingInfo.Uploader != "" {
+		textFile := transcodingInfo.OutputDir + WATERMARK_TEXT_FILE
+		if err := os.WriteFile(textFile, []byte("@"+transcodingInfo.Uploader), 0644); err != nil {
+			utils.ErrorLog("写入文字水印失败", "transcoding", err.Error())
+		} else {
+			options.TextFile = WATERMARK_TEXT_FILE
+		}
+
+		if conf.FontFile != "" {
+			if fontFile, err := filepath.Abs(conf.FontFile); err == nil {
+				options.FontFile = fontFile
+			}
+		}
+	}
+
+	if options.Image == "" && options.TextFile == "" {
+		return nil
+	}
+
+	return options
+}
+
+// 删除文字水印文件
+func removeWatermarkFile(outputDir string) {
+	os.Remove(outputDir + WATERMARK_TEXT_FILE)
+}
+
+// 生成水印滤镜，input为视频画面，imageInput为水印图片的输入流，返回滤镜及输出标签
+func (w *watermarkOptions) filters(input, imageInput string) ([]string, string) {
+	filters := make([]string, 0, 2)
+	margin := utils.Max(int(float64(utils.Min(w.Width, w.Height))*WATERMARK_MARGIN), 1)
+	fontSize := utils.Max(int(float64(utils.Min(w.Width, w.Height))*WATERMARK_FONT_RATIO), 12)
+	top := w.Position == global.WATERMARK_TOP_LEFT || w.Position == global.WATERMARK_TOP_RIGHT
+	left := w.Position == global.WATERMARK_TOP_LEFT || w.Position == global.WATERMARK_BOTTOM_LEFT
+
+	chain := input
+	if w.Image != "" {
+		// 同时有文字水印时，图片向内偏移一行文字的高度
+		offset := margin
+		if w.TextFile != "" {
+			offset += fontSize * 3 / 2
+		}
+
+		x, y := fmt.Sprintf("W-w-%d", margin), fmt.Sprintf("H-h-%d", offset)
+		if left {
+			x = fmt.Sprint(margin)
+		}
+		if top {
+			y = fmt.Sprint(offset)
+		}
+
+		imageWidth := utils.Max(int(float64(w.Width)*w.Scale)/2*2, 2)
+		filters = append(filters, fmt.Sprintf("[%s]scale=%d:-1,format=rgba,colorchannelmixer=aa=%g[wm]", imageInput, imageWidth, w.Opacity))
+		chain += fmt.Sprintf("[wm]overlay=x=%s:y=%s", x, y)
+	}
+
+	if w.TextFile != "" {
+		x, y := fmt.Sprintf("w-tw-%d", margin), fmt.Sprintf("h-th-%d", margin)
+		if left {
+			x = fmt.Sprint(margin)
+		}
+		if top {
+			y = fmt.Sprint(margin)
+		}
+
+		if w.Image != "" {
+			chain += ","
+		}
+		chain += fmt.Sprintf("drawtext=textfile=%s:expansion=none:fontsize=%d:fontcolor=white@%g:shadowcolor=black@%g:shadowx=1:shadowy=1:x=%s:y=%s",
+			w.TextFile, fontSize, w.Opacity, w.Opacity, x, y)
+		if w.FontFile != "" {
+			chain += ":fontfile=" + escapeFilterValue(w.FontFile)
+		}
+	}
+
+	filters = append(filters, chain+"[wmv]")
+	return filters, "[wmv]"
+}
+
+// 转义滤镜参数中的路径
+func escapeFilterValue(value string) string {
+	value = filepath.ToSlash(value)
+	return strings.ReplaceAll(value, ":", `\\:`)
+}
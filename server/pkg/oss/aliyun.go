@@ -3,6 +3,8 @@ package oss
 import (
 	"errors"
 	"io"
+	"strconv"
+	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"interastral-peace.com/alnitak/utils"
@@ -77,6 +79,34 @@ func (a *Aliyun) IsExists(objectKey string) (bool, error) {
 	return a.bucket.IsObjectExist(objectKey)
 }
 
+// 获取文件大小
+func (a *Aliyun) GetObjectSize(objectKey string) (int64, error) {
+	header, err := a.bucket.GetObjectMeta(objectKey)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+}
+
+// 列出指定前缀的文件
+func (a *Aliyun) ListObjects(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	options := []oss.Option{oss.Prefix(prefix)}
+	for {
+		result, err := a.bucket.ListObjectsV2(options...)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Objects {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		options = []oss.Option{oss.Prefix(prefix), oss.ContinuationToken(result.NextContinuationToken)}
+	}
+}
+
 // 获取访问URL
 func (a *Aliyun) GetObjectUrl(objectKey string) string {
 	url, err := a.bucket.SignURL(objectKey, oss.HTTPGet, 1800)
@@ -87,3 +117,37 @@ func (a *Aliyun) GetObjectUrl(objectKey string) string {
 
 	return url
 }
+
+// 初始化分片上传
+func (a *Aliyun) InitiateMultipartUpload(objectKey string) (string, error) {
+	imur, err := a.bucket.InitiateMultipartUpload(objectKey)
+	if err != nil {
+		return "", err
+	}
+
+	return imur.UploadID, nil
+}
+
+// 生成上传分片的预签名URL
+func (a *Aliyun) PresignUploadPart(objectKey, uploadId string, partNumber int, expires time.Duration) (string, error) {
+	return a.bucket.SignURL(objectKey, oss.HTTPPut, int64(expires.Seconds()),
+		oss.AddParam("partNumber", strconv.Itoa(partNumber)), oss.AddParam("uploadId", uploadId))
+}
+
+// 完成分片上传
+func (a *Aliyun) CompleteMultipartUpload(objectKey, uploadId string, parts []UploadPart) error {
+	imur := oss.InitiateMultipartUploadResult{Bucket: a.config.Bucket, Key: objectKey, UploadID: uploadId}
+	uploadParts := make([]oss.UploadPart, 0, len(parts))
+	for _, part := range parts {
+		uploadParts = append(uploadParts, oss.UploadPart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	_, err := a.bucket.CompleteMultipartUpload(imur, uploadParts)
+	return err
+}
+
+// 取消分片上传
+func (a *Aliyun) AbortMultipartUpload(objectKey, uploadId string) error {
+	imur := oss.InitiateMultipartUploadResult{Bucket: a.config.Bucket, Key: objectKey, UploadID: uploadId}
+	return a.bucket.AbortMultipartUpload(imur)
+}
@@ -149,6 +149,37 @@ func (m *MinIOStorage) IsExists(objectKey string) (bool, error) {
 	return true, nil
 }
 
+// 获取文件大小
+func (m *MinIOStorage) GetObjectSize(objectKey string) (int64, error) {
+	output, err := m.client.HeadObject(context.Background(), &s3.HeadObjectInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return aws.ToInt64(output.ContentLength), nil
+}
+
+// 列出指定前缀的文件
+func (m *MinIOStorage) ListObjects(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	paginator := s3.NewListObjectsV2Paginator(m.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(m.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
 // 获取访问URL
 func (m *MinIOStorage) GetObjectUrl(objectKey string) string {
 	signer := s3.NewPresignClient(m.client)
@@ -162,3 +193,61 @@ func (m *MinIOStorage) GetObjectUrl(objectKey string) string {
 	}
 	return presignedURL.URL
 }
+
+// 初始化分片上传
+func (m *MinIOStorage) InitiateMultipartUpload(objectKey string) (string, error) {
+	output, err := m.client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{
+		Bucket: aws.String(m.bucket),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return aws.ToString(output.UploadId), nil
+}
+
+// 生成上传分片的预签名URL
+func (m *MinIOStorage) PresignUploadPart(objectKey, uploadId string, partNumber int, expires time.Duration) (string, error) {
+	signer := s3.NewPresignClient(m.client)
+	presignedURL, err := signer.PresignUploadPart(context.Background(), &s3.UploadPartInput{
+		Bucket:     aws.String(m.bucket),
+		Key:        aws.String(objectKey),
+		UploadId:   aws.String(uploadId),
+		PartNumber: aws.Int32(int32(partNumber)),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+
+	return presignedURL.URL, nil
+}
+
+// 完成分片上传
+func (m *MinIOStorage) CompleteMultipartUpload(objectKey, uploadId string, parts []UploadPart) error {
+	completedParts := make([]types.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, types.CompletedPart{
+			PartNumber: aws.Int32(int32(part.PartNumber)),
+			ETag:       aws.String(part.ETag),
+		})
+	}
+
+	_, err := m.client.CompleteMultipartUpload(context.Background(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(m.bucket),
+		Key:             aws.String(objectKey),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	return err
+}
+
+// 取消分片上传
+func (m *MinIOStorage) AbortMultipartUpload(objectKey, uploadId string) error {
+	_, err := m.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(m.bucket),
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadId),
+	})
+	return err
+}
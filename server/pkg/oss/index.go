@@ -3,6 +3,7 @@ package oss
 import (
 	"errors"
 	"io"
+	"time"
 
 	"interastral-peace.com/alnitak/internal/config"
 	"interastral-peace.com/alnitak/utils"
@@ -22,7 +23,21 @@ type Storage interface {
 	PutObject(objectKey string, reader io.Reader) error
 	PutObjectFromFile(objectKey, filePath string) error
 	IsExists(objectKey string) (bool, error)
+	GetObjectSize(objectKey string) (int64, error)
+	ListObjects(prefix string) ([]string, error)
 	GetObjectUrl(objectKey string) string
+
+	// 分片上传，客户端使用预签名URL直接上传分片
+	InitiateMultipartUpload(objectKey string) (string, error)
+	PresignUploadPart(objectKey, uploadId string, partNumber int, expires time.Duration) (string, error)
+	CompleteMultipartUpload(objectKey, uploadId string, parts []UploadPart) error
+	AbortMultipartUpload(objectKey, uploadId string) error
+}
+
+// 已上传的分片
+type UploadPart struct {
+	PartNumber int
+	ETag       string
 }
 
 func InitStorage(c config.Storage) Storage {
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/minio/minio-go/v7"
@@ -139,6 +142,27 @@ func (m *MinIO) IsExists(objectKey string) (bool, error) {
 	return true, nil
 }
 
+// 获取文件大小
+func (m *MinIO) GetObjectSize(objectKey string) (int64, error) {
+	info, err := m.client.StatObject(context.Background(), m.config.Bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return info.Size, nil
+}
+
+// 列出指定前缀的文件
+func (m *MinIO) ListObjects(prefix string) ([]string, error) {
+	keys := make([]string, 0)
+	for object := range m.client.ListObjects(context.Background(), m.config.Bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		keys = append(keys, object.Key)
+	}
+	return keys, nil
+}
+
 // 获取访问URL
 func (m *MinIO) GetObjectUrl(objectKey string) string {
 	// 为对象生成一个预签名的 URL，具有更长的有效期（例如 2 小时）
@@ -150,3 +174,40 @@ func (m *MinIO) GetObjectUrl(objectKey string) string {
 	}
 	return presignedURL.String()
 }
+
+// 初始化分片上传
+func (m *MinIO) InitiateMultipartUpload(objectKey string) (string, error) {
+	core := minio.Core{Client: m.client}
+	return core.NewMultipartUpload(context.Background(), m.config.Bucket, objectKey, minio.PutObjectOptions{})
+}
+
+// 生成上传分片的预签名URL
+func (m *MinIO) PresignUploadPart(objectKey, uploadId string, partNumber int, expires time.Duration) (string, error) {
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadId)
+	presignedURL, err := m.client.Presign(context.Background(), http.MethodPut, m.config.Bucket, objectKey, expires, params)
+	if err != nil {
+		return "", err
+	}
+
+	return presignedURL.String(), nil
+}
+
+// 完成分片上传
+func (m *MinIO) CompleteMultipartUpload(objectKey, uploadId string, parts []UploadPart) error {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	core := minio.Core{Client: m.client}
+	_, err := core.CompleteMultipartUpload(context.Background(), m.config.Bucket, objectKey, uploadId, completeParts, minio.PutObjectOptions{})
+	return err
+}
+
+// 取消分片上传
+func (m *MinIO) AbortMultipartUpload(objectKey, uploadId string) error {
+	core := minio.Core{Client: m.client}
+	return core.AbortMultipartUpload(context.Background(), m.config.Bucket, objectKey, uploadId)
+}
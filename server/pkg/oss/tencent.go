@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/tencentyun/cos-go-sdk-v5"
@@ -121,6 +122,34 @@ func (t *TencentCOS) IsExists(objectKey string) (bool, error) {
 	return true, nil
 }
 
+func (t *TencentCOS) GetObjectSize(objectKey string) (int64, error) {
+	// 获取文件元信息
+	resp, err := t.client.Object.Head(context.Background(), objectKey, nil)
+	if err != nil {
+		return 0, err
+	}
+	return resp.ContentLength, nil
+}
+
+func (t *TencentCOS) ListObjects(prefix string) ([]string, error) {
+	// 分页列出指定前缀的文件
+	keys := make([]string, 0)
+	opt := &cos.BucketGetOptions{Prefix: prefix, MaxKeys: 1000}
+	for {
+		result, _, err := t.client.Bucket.Get(context.Background(), opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		opt.Marker = result.NextMarker
+	}
+}
+
 func (t *TencentCOS) GetObjectUrl(objectKey string) string {
 	// 获取腾讯云 COS 的文件签名 URL，指定有效期为 24 小时
 	presignedURL, err := t.client.Object.GetPresignedURL(
@@ -139,3 +168,44 @@ func (t *TencentCOS) GetObjectUrl(objectKey string) string {
 	}
 	return presignedURL.String()
 }
+
+// 初始化分片上传
+func (t *TencentCOS) InitiateMultipartUpload(objectKey string) (string, error) {
+	result, _, err := t.client.Object.InitiateMultipartUpload(context.Background(), objectKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return result.UploadID, nil
+}
+
+// 生成上传分片的预签名URL
+func (t *TencentCOS) PresignUploadPart(objectKey, uploadId string, partNumber int, expires time.Duration) (string, error) {
+	query := url.Values{}
+	query.Set("partNumber", strconv.Itoa(partNumber))
+	query.Set("uploadId", uploadId)
+	presignedURL, err := t.client.Object.GetPresignedURL(context.Background(), http.MethodPut, objectKey,
+		t.config.KeyID, t.config.KeySecret, expires, &cos.PresignedURLOptions{Query: &query})
+	if err != nil {
+		return "", err
+	}
+
+	return presignedURL.String(), nil
+}
+
+// 完成分片上传
+func (t *TencentCOS) CompleteMultipartUpload(objectKey, uploadId string, parts []UploadPart) error {
+	opt := &cos.CompleteMultipartUploadOptions{}
+	for _, part := range parts {
+		opt.Parts = append(opt.Parts, cos.Object{PartNumber: part.PartNumber, ETag: part.ETag})
+	}
+
+	_, _, err := t.client.Object.CompleteMultipartUpload(context.Background(), objectKey, uploadId, opt)
+	return err
+}
+
+// 取消分片上传
+func (t *TencentCOS) AbortMultipartUpload(objectKey, uploadId string) error {
+	_, err := t.client.Object.AbortMultipartUpload(context.Background(), objectKey, uploadId)
+	return err
+}
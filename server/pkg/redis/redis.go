@@ -77,6 +77,11 @@ func (r *Redis) ZRemRangeByRank(key string, start, stop int64) {
 	r.redisClient.ZRemRangeByRank(r.ctx, key, start, stop)
 }
 
+// 有序集中指定分数区间内的成员
+func (r *Redis) ZRangeByScore(key, min, max string) []string {
+	return r.redisClient.ZRangeByScore(r.ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Val()
+}
+
 // 向有序集合插入数据
 func (r *Redis) ZRem(key string, member ...interface{}) {
 	r.redisClient.ZRem(r.ctx, key, member...)
@@ -101,3 +106,45 @@ func (r *Redis) SRandMemberN(key string, count int64) []string {
 func (r *Redis) SMembers(key string) []string {
 	return r.redisClient.SMembers(r.ctx, key).Val()
 }
+
+// 设置哈希表字段
+func (r *Redis) HSet(key, field string, value interface{}) {
+	r.redisClient.HSet(r.ctx, key, field, value)
+}
+
+// 获取哈希表所有字段
+func (r *Redis) HGetAll(key string) map[string]string {
+	return r.redisClient.HGetAll(r.ctx, key).Val()
+}
+
+// 从列表左侧插入数据
+func (r *Redis) LPush(key string, values ...interface{}) {
+	r.redisClient.LPush(r.ctx, key, values...)
+}
+
+// 阻塞地从列表右侧取出数据，超时返回false
+func (r *Redis) BRPop(timeout time.Duration, key string) (string, bool) {
+	result, err := r.redisClient.BRPop(r.ctx, timeout, key).Result()
+	if err != nil || len(result) < 2 {
+		return "", false
+	}
+	return result[1], true
+}
+
+// 发布消息
+func (r *Redis) Publish(channel string, message interface{}) {
+	r.redisClient.Publish(r.ctx, channel, message)
+}
+
+// 订阅频道，返回接收消息内容的通道
+func (r *Redis) Subscribe(channel string) <-chan string {
+	messages := make(chan string)
+	pubsub := r.redisClient.Subscribe(r.ctx, channel)
+	go func() {
+		defer close(messages)
+		for msg := range pubsub.Channel() {
+			messages <- msg.Payload
+		}
+	}()
+	return messages
+}
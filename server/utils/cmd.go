@@ -1,19 +1,54 @@
 package utils
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"io"
 	"os/exec"
 )
 
+// 执行命令，已设置的cmd.Stderr会同时收到错误输出
 func RunCmd(cmd *exec.Cmd) (bytes.Buffer, error) {
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	cmd.Stderr = teeStderr(&stderr, cmd.Stderr)
 	if err := cmd.Run(); err != nil {
 		return out, errors.New(stderr.String())
 	}
 
 	return out, nil
 }
+
+// 执行命令并逐行处理标准输出
+func RunCmdWithOutput(cmd *exec.Cmd, onLine func(line string)) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = teeStderr(&stderr, cmd.Stderr)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		onLine(scanner.Text())
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return errors.New(stderr.String())
+	}
+
+	return nil
+}
+
+func teeStderr(stderr *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return stderr
+	}
+
+	return io.MultiWriter(stderr, w)
+}
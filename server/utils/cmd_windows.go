@@ -0,0 +1,17 @@
+//go:build windows
+
+package utils
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// 在独立的进程组中执行命令，取消时结束整个进程树
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
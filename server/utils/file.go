@@ -1,6 +1,9 @@
 package utils
 
-import "os"
+import (
+	"io"
+	"os"
+)
 
 // 判断文件是否存在
 func IsFileExists(path string) bool {
@@ -9,3 +12,21 @@ func IsFileExists(path string) bool {
 	}
 	return true
 }
+
+// 复制文件
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"192.0.0.1", false},
+		{"198.18.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"::", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"64:ff9b::7f00:1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := IsPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("IsPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsIPInList(t *testing.T) {
+	list := []string{"10.0.0.5", "192.168.0.0/16", "fd00::/8", "invalid"}
+
+	tests := []struct {
+		ip   string
+		list []string
+		want bool
+	}{
+		{"10.0.0.5", list, true},
+		{"10.0.0.6", list, false},
+		{"192.168.10.20", list, true},
+		{"192.169.0.1", list, false},
+		{"fd12::1", list, true},
+		{"fe80::1", list, false},
+		{"10.0.0.5", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := IsIPInList(net.ParseIP(tt.ip), tt.list); got != tt.want {
+				t.Errorf("IsIPInList(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"io"
+	"os"
 )
 
 func GenerateSaltedMD5(input string, salt string) string {
@@ -12,3 +13,18 @@ func GenerateSaltedMD5(input string, salt string) string {
 	io.WriteString(hasher, saltedInput)
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+// 计算文件的md5
+func FileMD5(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
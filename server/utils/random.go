@@ -1,6 +1,8 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"encoding/hex"
 	"math/rand"
 	"strconv"
 	"time"
@@ -17,3 +19,12 @@ func GenerateNumberCode(length int) string {
 	}
 	return res
 }
+
+// 生成n字节安全随机数，以hex编码返回
+func GenerateSecureHex(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
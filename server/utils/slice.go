@@ -21,3 +21,12 @@ func IsUintInSlice(nums []uint, target uint) bool {
 	}
 	return false
 }
+
+func IsStringInSlice(s []string, target string) bool {
+	for _, v := range s {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
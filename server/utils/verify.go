@@ -2,6 +2,7 @@ package utils
 
 import (
 	"regexp"
+	"strings"
 )
 
 const MB = 1024 * 1024
@@ -61,3 +62,10 @@ func FileSize(fileSize int64, count int64, targetSize int64) bool {
 	}
 	return true
 }
+
+// 验证是否为字幕
+func IsSubtitleType(suffix string) bool {
+	pattern := `^\.(srt|ass|ssa|vtt)$`
+	reg := regexp.MustCompile(pattern)
+	return reg.MatchString(strings.ToLower(suffix))
+}
@@ -80,6 +80,23 @@ CREATE TABLE `casbin_rule`  (
   UNIQUE INDEX `idx_casbin_rule`(`ptype`, `v0`, `v1`, `v2`, `v3`, `v4`, `v5`) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 1 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for chapter
+-- ----------------------------
+DROP TABLE IF EXISTS `chapter`;
+CREATE TABLE `chapter`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `start` double NOT NULL COMMENT '开始时间(秒)',
+  `title` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '章节标题',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_chapter_deleted_at`(`deleted_at`) USING BTREE,
+  INDEX `idx_chapter_resource_id`(`resource_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for collect_article
 -- ----------------------------
@@ -413,6 +430,8 @@ CREATE TABLE `resource`  (
   `duration` double NULL DEFAULT 0 COMMENT '视频时长',
   `status` bigint NOT NULL COMMENT '审核状态',
   `codec_name` varchar(10) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '视频编码名称',
+  `watermark` tinyint(1) NULL DEFAULT 0 COMMENT '转码时是否添加水印',
+  `clips` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '剪辑保留的片段',
   PRIMARY KEY (`id`) USING BTREE,
   INDEX `idx_resource_vid`(`vid`) USING BTREE,
   INDEX `idx_resource_uid`(`uid`) USING BTREE,
@@ -472,6 +491,98 @@ CREATE TABLE `role_menu`  (
   CONSTRAINT `fk_role_menu_role` FOREIGN KEY (`role_id`) REFERENCES `role` (`id`) ON DELETE RESTRICT ON UPDATE RESTRICT
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for storyboard
+-- ----------------------------
+DROP TABLE IF EXISTS `storyboard`;
+CREATE TABLE `storyboard`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '目录名称',
+  `content` mediumtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '缩略图WebVTT',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_storyboard_deleted_at`(`deleted_at`) USING BTREE,
+  UNIQUE INDEX `idx_storyboard_resource_id`(`resource_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for subtitle
+-- ----------------------------
+DROP TABLE IF EXISTS `subtitle`;
+CREATE TABLE `subtitle`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `uid` bigint UNSIGNED NULL DEFAULT NULL COMMENT '所属用户',
+  `lang` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '语言代码',
+  `name` varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '显示名称',
+  `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '目录名称',
+  `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '字幕播放列表',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_subtitle_deleted_at`(`deleted_at`) USING BTREE,
+  INDEX `idx_subtitle_resource_id`(`resource_id`) USING BTREE,
+  INDEX `idx_subtitle_uid`(`uid`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for transcoding_log
+-- ----------------------------
+DROP TABLE IF EXISTS `transcoding_log`;
+CREATE TABLE `transcoding_log`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `task_id` bigint UNSIGNED NULL DEFAULT NULL COMMENT '转码任务ID',
+  `stage` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '执行阶段',
+  `target` varchar(500) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '输出目标',
+  `command` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '执行的命令',
+  `duration` bigint NULL DEFAULT NULL COMMENT '耗时(毫秒)',
+  `exit_code` bigint NULL DEFAULT NULL COMMENT '退出码',
+  `stderr` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '错误输出的末尾部分',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_transcoding_log_deleted_at`(`deleted_at`) USING BTREE,
+  INDEX `idx_transcoding_log_resource_id`(`resource_id`) USING BTREE,
+  INDEX `idx_transcoding_log_task_id`(`task_id`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
+-- ----------------------------
+-- Table structure for transcoding_task
+-- ----------------------------
+DROP TABLE IF EXISTS `transcoding_task`;
+CREATE TABLE `transcoding_task`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `vid` bigint UNSIGNED NULL DEFAULT NULL COMMENT '所属视频',
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '目录名称',
+  `status` bigint NOT NULL COMMENT '任务状态',
+  `retries` bigint NULL DEFAULT 0 COMMENT '已重试次数',
+  `next_run_at` datetime(3) NULL DEFAULT NULL COMMENT '下次执行时间',
+  `error` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '失败原因',
+  `retranscode` tinyint(1) NULL DEFAULT 0 COMMENT '是否为重新转码',
+  `clip` tinyint(1) NULL DEFAULT 0 COMMENT '是否为剪辑',
+  `clips` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '剪辑保留的片段，任务完成时写入资源',
+  `output_dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '输出目录名称，为空时与源目录相同',
+  `replaced_dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '被替换的切片目录，等待清理',
+  `worker` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '执行任务的工作进程',
+  `heartbeat_at` datetime(3) NULL DEFAULT NULL COMMENT '最后心跳时间',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_transcoding_task_deleted_at`(`deleted_at`) USING BTREE,
+  INDEX `idx_transcoding_task_vid`(`vid`) USING BTREE,
+  INDEX `idx_transcoding_task_resource_id`(`resource_id`) USING BTREE,
+  INDEX `idx_transcoding_task_status`(`status`) USING BTREE,
+  INDEX `idx_transcoding_task_next_run_at`(`next_run_at`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 -- ----------------------------
 -- Table structure for user
 -- ----------------------------
@@ -535,6 +646,12 @@ CREATE TABLE `video_index_file`  (
   `quality` longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '视频质量',
   `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '目录名称',
   `content` text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '文件内容',
+  `bandwidth` bigint NULL DEFAULT 0 COMMENT '峰值码率',
+  `width` bigint NULL DEFAULT 0 COMMENT '视频宽度',
+  `height` bigint NULL DEFAULT 0 COMMENT '视频高度',
+  `codecs` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '编码格式',
+  `frame_rate` double NULL DEFAULT 0 COMMENT '帧率',
+  `start_pts` bigint NULL DEFAULT NULL COMMENT '首个切片的起始时间戳(90kHz)，为空时未知',
   PRIMARY KEY (`id`) USING BTREE,
   INDEX `idx_video_index_file_deleted_at`(`deleted_at`) USING BTREE,
   INDEX `idx_video_index_file_resource_id`(`resource_id`) USING BTREE
@@ -554,10 +671,31 @@ CREATE TABLE `video_file`  (
   `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '目录名称',
   `hash` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT '文件hash',
   `chunks_count` bigint NULL DEFAULT NULL COMMENT '分片数量',
+  `size` bigint NULL DEFAULT 0 COMMENT '文件大小，tus上传时使用',
+  `verified` tinyint(1) NULL DEFAULT 0 COMMENT '文件已通过hash校验',
+  `upload_id` varchar(255) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL DEFAULT NULL COMMENT 'OSS分片上传ID，直传时使用',
   PRIMARY KEY (`id`) USING BTREE,
   INDEX `idx_video_file_deleted_at`(`deleted_at` ASC) USING BTREE,
   INDEX `idx_video_file_uid`(`uid` ASC) USING BTREE,
   INDEX `idx_video_file_dir_name`(`dir_name` ASC) USING BTREE
 ) ENGINE = InnoDB AUTO_INCREMENT = 3 CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
 
+-- ----------------------------
+-- Table structure for video_key
+-- ----------------------------
+DROP TABLE IF EXISTS `video_key`;
+CREATE TABLE `video_key`  (
+  `id` bigint UNSIGNED NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) NULL DEFAULT NULL,
+  `updated_at` datetime(3) NULL DEFAULT NULL,
+  `deleted_at` datetime(3) NULL DEFAULT NULL,
+  `resource_id` bigint UNSIGNED NOT NULL COMMENT '视频资源ID',
+  `dir_name` varchar(20) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '目录名称',
+  `secret` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT 'AES-128密钥(hex)',
+  `iv` varchar(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NOT NULL COMMENT '初始向量(hex)',
+  PRIMARY KEY (`id`) USING BTREE,
+  INDEX `idx_video_key_deleted_at`(`deleted_at`) USING BTREE,
+  UNIQUE INDEX `idx_video_key_resource_dir`(`resource_id`, `dir_name`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci ROW_FORMAT = Dynamic;
+
 SET FOREIGN_KEY_CHECKS = 1;
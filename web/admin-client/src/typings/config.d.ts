@@ -23,5 +23,4 @@ interface StorageConfigType {
   region: string;
   domain: string;
   private: boolean;
-  uploadMp4File: boolean;
 }
\ No newline at end of file
@@ -41,7 +41,12 @@ export const uploadFileChunkAPI = async ({ name, file, action, onProgress, onFin
 
 
   const tasks: number[] = []
-  const uploadedChunks = await getUploadedChunksAPI(hash)
+  const { chunks: uploadedChunks, instant } = await getUploadedChunksAPI(hash, file.name)
+  // 服务器已有相同文件时秒传
+  if (instant) {
+    finishUploadAPI({ hash, action, onFinish, onError })
+    return { controllers: [] };
+  }
   for (let i = 0; i < totalChunks; i++) {
     if (!uploadedChunks.includes(i)) {
       tasks.push(i)
@@ -56,7 +61,7 @@ export const uploadFileChunkAPI = async ({ name, file, action, onProgress, onFin
   // 如果服务器不存在数据则手动上传第一个分片
   if (tasks.length === totalChunks) {
     const chunk = file.slice(0, Math.min(CHUNK_SIZE, file.size));
-    const formData = formDataGenerator(chunk, "0");
+    const formData = formDataGenerator(chunk, "0", await getFileMD5(chunk));
     const controller = new AbortController();
     const firstChunkRes = await uploadChunkAPI(formData, controller)
     if (firstChunkRes.data.code === statusCode.OK) {
@@ -86,10 +91,10 @@ export const uploadFileChunkAPI = async ({ name, file, action, onProgress, onFin
     const start = i * CHUNK_SIZE;
     const end = Math.min(start + CHUNK_SIZE, file.size);
     const chunk = file.slice(start, end);
-    const formData = formDataGenerator(chunk, i.toString());
     const controller = new AbortController();
     controllers.push(controller);
     try {
+      const formData = formDataGenerator(chunk, i.toString(), await getFileMD5(chunk));
       const res = await uploadChunkAPI(formData, controller);
       if (res.data.code === statusCode.OK) {
         uploadedChunksCount++;
@@ -126,10 +131,11 @@ const createFormDataGenerator = (hash: string, name: string, fileName: string, t
   const savedFileName = fileName;
   const savedTotalChunks = totalChunks;
 
-  return (chunk: Blob, i: string) => {
+  return (chunk: Blob, i: string, chunkHash: string) => {
     const formData = new FormData();
     formData.append(savedName, chunk);
     formData.append('hash', savedHash);
+    formData.append('chunkHash', chunkHash);
     formData.append('name', savedFileName);
     formData.append('chunkIndex', i.toString());
     formData.append('totalChunks', savedTotalChunks);
@@ -138,17 +144,13 @@ const createFormDataGenerator = (hash: string, name: string, fileName: string, t
   };
 }
 
-const getUploadedChunksAPI = async (hash: string) => {
-  const res = await request.post("v1/upload/checkVideo", { hash }, {})
+const getUploadedChunksAPI = async (hash: string, name: string): Promise<{ chunks: number[], instant: boolean }> => {
+  const res = await request.post("v1/upload/checkVideo", { hash, name }, {})
   if (res.data.code === statusCode.OK) {
-    if (res.data.data.chunks) {
-      return res.data.data.chunks
-    }
-
-    return []
+    return { chunks: res.data.data.chunks || [], instant: !!res.data.data.instant }
   }
 
-  return []
+  return { chunks: [], instant: false }
 }
 
 const uploadChunkAPI = (formData: FormData, controller?: AbortController) => {
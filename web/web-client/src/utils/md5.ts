@@ -1,6 +1,6 @@
 import SparkMD5 from 'spark-md5';
 
-export const getFileMD5 = (file: File): Promise<string> => {
+export const getFileMD5 = (file: Blob): Promise<string> => {
   return new Promise((resolve, reject) => {
     const fileReader = new FileReader();
     const spark = new SparkMD5.ArrayBuffer();